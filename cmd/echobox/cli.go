@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/Elagoht/echobox/internal/config"
+	"github.com/Elagoht/echobox/internal/cors"
+	"github.com/Elagoht/echobox/internal/health"
+	"github.com/Elagoht/echobox/internal/router"
+	"github.com/Elagoht/echobox/internal/tracing"
+	"github.com/Elagoht/echobox/internal/version"
+)
+
+const usage = `Usage: echobox [command] [flags]
+
+Commands:
+  serve              Start the server (default)
+  version            Print version information
+  config validate    Check the configuration and exit
+  help               Show this help
+
+Every setting can be given as a flag or an environment variable. Flags take
+precedence over environment variables, which take precedence over defaults.
+Run "echobox serve --help" to list the flags.
+`
+
+// run executes the command named by args and returns the process exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	command := "serve"
+	switch {
+	case len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help"):
+		// Help for serve alone is "echobox serve --help"
+		command, args = "help", args[1:]
+	case len(args) > 0 && args[0] != "" && args[0][0] != '-':
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		return runServe(args, stderr)
+	case "version":
+		fmt.Fprintln(stdout, version.Get())
+		return 0
+	case "config":
+		if len(args) == 0 || args[0] != "validate" {
+			fmt.Fprint(stderr, "Usage: echobox config validate [flags]\n")
+			return 2
+		}
+		return runValidate(args[1:], stdout, stderr)
+	case "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", command, usage)
+		return 2
+	}
+}
+
+// parseConfig builds the configuration from defaults, the configuration
+// file, the environment and flags, each overriding the previous. ok is false
+// when the process should exit with code, after help or a usage error.
+func parseConfig(name string, args []string, stderr io.Writer) (cfg *config.Server, code int, ok bool) {
+	newFlagSet := func(cfg *config.Server, path *string) *flag.FlagSet {
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		fs.StringVar(path, "config", *path, "YAML, JSON or TOML configuration file (env ECHOBOX_CONFIG)")
+		cfg.RegisterFlags(fs)
+		return fs
+	}
+
+	// The file sits below flags in precedence but names the starting point for
+	// them, so find --config first and ignore everything else on this pass
+	path := os.Getenv("ECHOBOX_CONFIG")
+	pre := newFlagSet(config.Default(), &path)
+	pre.SetOutput(io.Discard)
+	pre.Parse(args)
+
+	cfg = config.LoadFrom(path)
+	fs := newFlagSet(cfg, &path)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: echobox %s [flags]\n\nFlags:\n", name)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, 0, false
+		}
+		return nil, 2, false
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return nil, 2, false
+	}
+	return cfg, 0, true
+}
+
+func runServe(args []string, stderr io.Writer) int {
+	cfg, code, ok := parseConfig("serve", args, stderr)
+	if !ok {
+		return code
+	}
+	if err := serve(context.Background(), cfg); err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	return 0
+}
+
+func runValidate(args []string, stdout, stderr io.Writer) int {
+	cfg, code, ok := parseConfig("config validate", args, stderr)
+	if !ok {
+		return code
+	}
+	if err := validate(cfg); err != nil {
+		fmt.Fprintf(stderr, "invalid configuration:\n%v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, "configuration is valid")
+	return 0
+}
+
+// validate checks cfg, then the CORS origins and routes, which only the
+// packages serving them can parse.
+func validate(cfg *config.Server) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	var errs []error
+	for i, origin := range cfg.CORS.Origins {
+		if _, _, err := cors.CompileOrigins([]string{origin}); err != nil {
+			errs = append(errs, fmt.Errorf("cors.origins[%d]: %w", i, err))
+		}
+	}
+	routes := make([]router.Route, len(cfg.Routes))
+	for i, r := range cfg.Routes {
+		routes[i] = router.Route(r)
+	}
+	errs = append(errs, router.CheckRoutes(routes...))
+	return errors.Join(errs...)
+}
+
+func serve(ctx context.Context, cfg *config.Server) error {
+	if err := validate(cfg); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	tlsConfig, ca, err := createTLSConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	state := health.New()
+	opts := []router.Option{router.WithHealth(state)}
+	if ca != nil {
+		opts = append(opts, router.WithCA(ca.CertPEM()))
+	}
+	if tlsConfig != nil && tlsConfig.ClientCAs != nil {
+		opts = append(opts, router.WithClientCAs(tlsConfig.ClientCAs))
+	}
+	var exporter *tracing.Exporter
+	if cfg.Tracing.Endpoint != "" {
+		exporter = tracing.NewExporter(tracing.ExporterOptions{
+			Endpoint:       cfg.Tracing.Endpoint,
+			ServiceName:    cfg.Tracing.ServiceName,
+			ServiceVersion: version.Get().Version,
+		})
+		opts = append(opts, router.WithTracing(exporter))
+	}
+	server, err := createServer(cfg, opts...)
+	if err != nil {
+		return err
+	}
+
+	services, err := createServices(cfg, server, tlsConfig)
+	if err != nil {
+		return err
+	}
+	if exporter != nil {
+		// Outlive every listener, so spans of requests finishing during the
+		// shutdown are still exported
+		exportCtx, stopExport := context.WithCancel(context.WithoutCancel(ctx))
+		exported := make(chan error, 1)
+		go func() {
+			exported <- exporter.Run(exportCtx)
+		}()
+		defer func() {
+			stopExport()
+			<-exported
+		}()
+	}
+
+	return runServer(ctx, server, shutdown{health: state, delay: cfg.ShutdownDelay, timeout: cfg.ShutdownTimeout}, services...)
+}
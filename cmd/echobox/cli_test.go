@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Elagoht/echobox/internal/config"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{name: "version", args: []string{"version"}, wantStdout: "echobox "},
+		{name: "help", args: []string{"help"}, wantStdout: "config validate"},
+		{name: "help flag", args: []string{"--help"}, wantStdout: "config validate"},
+		{name: "short help flag", args: []string{"-h"}, wantStdout: "Commands:"},
+		{name: "serve help", args: []string{"serve", "-h"}, wantStderr: "(env PORT)"},
+		{name: "unknown command", args: []string{"frobnicate"}, wantCode: 2, wantStderr: "unknown command"},
+		{name: "unknown flag", args: []string{"serve", "--nope"}, wantCode: 2, wantStderr: "not defined"},
+		{name: "extra argument", args: []string{"serve", "extra"}, wantCode: 2, wantStderr: "unexpected argument"},
+		{name: "config without subcommand", args: []string{"config"}, wantCode: 2, wantStderr: "config validate"},
+		{name: "config validate", args: []string{"config", "validate"}, wantStdout: "configuration is valid"},
+		{name: "config validate with flags", args: []string{"config", "validate", "--port", "x", "--raw-echo-mode", "words"}, wantCode: 1, wantStderr: "raw_echo.mode"},
+		{name: "config validate CORS origin", args: []string{"config", "validate", "--cors-origins", "*,/(/"}, wantCode: 1, wantStderr: "cors.origins[1]"},
+		{name: "serve invalid config", args: []string{"serve", "--port", "invalid"}, wantCode: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tt.args, &stdout, &stderr)
+
+			if code != tt.wantCode {
+				t.Errorf("run(%v) = %d, want %d (stderr: %s)", tt.args, code, tt.wantCode, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.wantStdout) {
+				t.Errorf("run(%v) stdout = %q, want it to contain %q", tt.args, stdout.String(), tt.wantStdout)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("run(%v) stderr = %q, want it to contain %q", tt.args, stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestRun_EnvironmentFallback(t *testing.T) {
+	os.Setenv("RAW_ECHO_MODE", "words")
+	defer os.Unsetenv("RAW_ECHO_MODE")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"config", "validate"}, &stdout, &stderr); code != 1 {
+		t.Errorf("run() = %d, want 1 for invalid RAW_ECHO_MODE", code)
+	}
+
+	// A flag overrides the invalid environment value
+	stderr.Reset()
+	if code := run([]string{"config", "validate", "--raw-echo-mode", "line"}, &stdout, &stderr); code != 0 {
+		t.Errorf("run() = %d, want 0 when the flag overrides the environment (stderr: %s)", code, stderr.String())
+	}
+}
+
+func TestRun_ConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "echobox.toml")
+	os.WriteFile(path, []byte("[raw_echo]\nmode = \"words\"\n"), 0o600)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"config", "validate", "--config", path}, &stdout, &stderr); code != 1 {
+		t.Errorf("run() = %d, want 1 for invalid raw_echo.mode in the file", code)
+	}
+
+	// Flags still override the file
+	stderr.Reset()
+	if code := run([]string{"config", "validate", "--raw-echo-mode", "raw", "--config=" + path}, &stdout, &stderr); code != 0 {
+		t.Errorf("run() = %d, want 0 when a flag overrides the file (stderr: %s)", code, stderr.String())
+	}
+
+	stderr.Reset()
+	if code := run([]string{"config", "validate", "--config", path + ".missing"}, &stdout, &stderr); code != 1 {
+		t.Errorf("run() = %d, want 1 for a missing config file", code)
+	}
+}
+
+func TestRun_ConfigFileRoutes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "echobox.yaml")
+	os.WriteFile(path, []byte("routes:\n  - path: /ping\n  - path: /_health\n  - path: /{x}/5\n    methods: [GET]\n"), 0o600)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"config", "validate", "--config", path}, &stdout, &stderr); code != 1 {
+		t.Errorf("run() = %d, want 1 for routes that cannot be served", code)
+	}
+	for _, want := range []string{`routes[1]: "GET /_health" is already registered`, "routes[2]: "} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("run() stderr = %q, want it to contain %q", stderr.String(), want)
+		}
+	}
+	if strings.Contains(stderr.String(), "routes[0]") {
+		t.Errorf("run() stderr = %q, want no error for a valid route", stderr.String())
+	}
+}
+
+func TestServe_ExportsSpansDuringShutdown(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping shutdown test in short mode")
+	}
+
+	var mu sync.Mutex
+	var exported bytes.Buffer
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		io.Copy(&exported, r.Body)
+	}))
+	defer collector.Close()
+
+	cfg := config.Load()
+	cfg.Port = "5890"
+	cfg.ShutdownDelay = 0
+	cfg.AccessLog.Enabled = false
+	cfg.Tracing.Endpoint = collector.URL + "/v1/traces"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, cfg)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// Hold a request open across the start of the shutdown
+	body, bodyWriter := io.Pipe()
+	resp := make(chan *http.Response, 1)
+	go func() {
+		r, err := http.Post("http://localhost:5890/post", "text/plain", body)
+		if err != nil {
+			t.Errorf("POST /post error = %v", err)
+		}
+		resp <- r
+	}()
+	bodyWriter.Write([]byte("in flight"))
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+	bodyWriter.Close()
+
+	if r := <-resp; r == nil || r.StatusCode != http.StatusOK {
+		t.Fatalf("POST /post during shutdown = %v, want 200", r)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("serve() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server did not stop within timeout")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !strings.Contains(exported.String(), "/post") {
+		t.Errorf("exported spans = %s, want the request finished during shutdown", exported.String())
+	}
+}
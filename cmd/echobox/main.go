@@ -2,62 +2,467 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"sync"
 	"time"
 
+	"github.com/Elagoht/echobox/internal/accesslog"
+	"github.com/Elagoht/echobox/internal/certs"
 	"github.com/Elagoht/echobox/internal/config"
+	"github.com/Elagoht/echobox/internal/cors"
+	"github.com/Elagoht/echobox/internal/health"
+	"github.com/Elagoht/echobox/internal/listener"
+	"github.com/Elagoht/echobox/internal/metrics"
+	"github.com/Elagoht/echobox/internal/proxyproto"
+	"github.com/Elagoht/echobox/internal/rawecho"
+	"github.com/Elagoht/echobox/internal/requestid"
 	"github.com/Elagoht/echobox/internal/router"
+	"github.com/Elagoht/echobox/internal/wire"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
-func createServer() *http.Server {
-	cfg := config.Load()
+// service runs alongside the HTTP server until its context is cancelled.
+type service func(ctx context.Context) error
 
-	return &http.Server{
-		Addr:         ":" + cfg.Port,
-		Handler:      router.New(),
-		ReadTimeout:  time.Duration(cfg.ReadTimeout) * time.Second,
-		WriteTimeout: time.Duration(cfg.WriteTimeout) * time.Second,
+func createServer(cfg *config.Server, opts ...router.Option) (*http.Server, error) {
+	opts = append([]router.Option{
+		router.WithMaxBodyBytes(int64(cfg.MaxBodyBytes)),
+		router.WithBodyEchoLimit(int64(cfg.BodyEchoLimit)),
+		router.WithUpload(int64(cfg.Upload.MaxPartBytes), cfg.Upload.MaxParts),
+	}, opts...)
+	for _, r := range cfg.Routes {
+		opts = append(opts, router.WithRoutes(router.Route(r)))
 	}
+	if cfg.Metrics {
+		opts = append(opts, router.WithMetrics())
+	}
+	if cfg.Admin.Enabled {
+		opts = append(opts, router.WithAdmin(cfg.Admin.Token))
+	}
+
+	var handler http.Handler = router.New(opts...)
+	if cfg.CORS.Enabled {
+		c, err := newCORS(cfg)
+		if err != nil {
+			return nil, err
+		}
+		// Inside the access log, so answered preflights are logged
+		handler = c.Handler(handler)
+	}
+	if cfg.AccessLog.Enabled {
+		// Inside h2c, so upgraded and prior-knowledge HTTP/2 requests are logged
+		handler = newAccessLogger(cfg).Handler(handler)
+	}
+	if cfg.Metrics {
+		// Outside the access log so its time counts towards the latency
+		handler = metrics.Middleware(handler)
+	}
+	// Outermost, so the access log and handlers see the assigned ID
+	handler = requestid.Middleware(handler)
+
+	// Listeners that need configuring are attached by createServices instead
+	addr := ":" + cfg.Port
+	if len(cfg.Listen) > 0 || cfg.Proxy.Enabled || cfg.RawCapture {
+		addr = ""
+	}
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
+	tuneServer(server, cfg)
+	if cfg.H2C {
+		if err := serveH2C(server, cfg); err != nil {
+			return nil, err
+		}
+	}
+	var hooks []func(context.Context, net.Conn) context.Context
+	if cfg.Proxy.Enabled {
+		hooks = append(hooks, proxyproto.ConnContext)
+	}
+	if cfg.RawCapture {
+		hooks = append(hooks, wire.ConnContext)
+		server.ConnState = wire.ConnState
+	}
+	if len(hooks) > 0 {
+		server.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+			for _, hook := range hooks {
+				ctx = hook(ctx, c)
+			}
+			return ctx
+		}
+	}
+	return server, nil
+}
+
+func newCORS(cfg *config.Server) (*cors.CORS, error) {
+	c, err := cors.New(cors.Options{
+		Origins:       cfg.CORS.Origins,
+		Methods:       cfg.CORS.Methods,
+		Headers:       cfg.CORS.Headers,
+		ExposeHeaders: cfg.CORS.ExposeHeaders,
+		Credentials:   cfg.CORS.Credentials,
+		MaxAge:        cfg.CORS.MaxAge,
+		Reflect:       cfg.CORS.Reflect,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cors: %w", err)
+	}
+	return c, nil
+}
+
+func newAccessLogger(cfg *config.Server) *accesslog.Logger {
+	level, _ := accesslog.ParseLevel(cfg.AccessLog.Level)
+	return accesslog.New(os.Stdout, accesslog.Options{
+		Format:  cfg.AccessLog.Format,
+		Level:   level,
+		Headers: cfg.AccessLog.Headers,
+		Redact:  cfg.AccessLog.Redact,
+		Body:    cfg.AccessLog.Body,
+		Skip:    router.ProbePaths,
+	})
 }
 
-func runServer(ctx context.Context, server *http.Server) error {
-	log.Printf("Echobox listening on http://localhost:%s", server.Addr[1:])
+// tuneServer applies the configured timeouts and limits, so the HTTP and
+// HTTPS servers behave alike.
+func tuneServer(server *http.Server, cfg *config.Server) {
+	server.ReadTimeout = cfg.ReadTimeout
+	server.ReadHeaderTimeout = cfg.ReadHeaderTimeout
+	server.WriteTimeout = cfg.WriteTimeout
+	server.IdleTimeout = cfg.IdleTimeout
+	server.MaxHeaderBytes = cfg.MaxHeaderBytes
+	server.SetKeepAlivesEnabled(cfg.KeepAlive)
+}
+
+// serveH2C makes server accept HTTP/2 without TLS, both with prior knowledge
+// and via Upgrade. Those connections are hijacked from net/http: they take
+// MaxHeaderBytes from server and IdleTimeout through ConfigureServer, which
+// also sends them GOAWAY on Shutdown. Shutdown does not wait for them, so
+// streams still open when the process exits are cut off.
+func serveH2C(server *http.Server, cfg *config.Server) error {
+	h2s := &http2.Server{}
+	if n := cfg.MaxBodyBytes; n > 0 && n < 1<<20 {
+		// No point in letting a client send more than a body may hold
+		h2s.MaxUploadBufferPerStream = int32(n)
+	}
+	if err := http2.ConfigureServer(server, h2s); err != nil {
+		return fmt.Errorf("h2c: %w", err)
+	}
+	server.Handler = h2c.NewHandler(server.Handler, h2s)
+	return nil
+}
+
+// listenAddrs returns the addresses the HTTP server must be attached to by
+// createServices, or nil when it listens on its own Addr.
+func listenAddrs(cfg *config.Server, server *http.Server) []string {
+	switch {
+	case server.Addr != "":
+		return nil
+	case len(cfg.Listen) > 0:
+		return cfg.Listen
+	default:
+		return []string{":" + cfg.Port}
+	}
+}
+
+// createTLSConfig loads the configured certificate pair, or generates a CA
+// and leaf certificate in memory when none is given. The CA is nil when
+// TLS is disabled or certificates come from files.
+func createTLSConfig(cfg *config.Server) (*tls.Config, *certs.Authority, error) {
+	if cfg.TLS.Port == "" {
+		return nil, nil, nil
+	}
+
+	clientAuth, ok := clientAuthTypes[cfg.TLS.ClientAuth]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown TLS_CLIENT_AUTH %q", cfg.TLS.ClientAuth)
+	}
+
+	var (
+		cert tls.Certificate
+		ca   *certs.Authority
+		err  error
+	)
+
+	if cfg.TLS.CertFile != "" || cfg.TLS.KeyFile != "" {
+		if cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "" {
+			return nil, nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+		}
+		cert, err = tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading TLS certificate: %w", err)
+		}
+	} else {
+		if ca, err = certs.NewAuthority(); err != nil {
+			return nil, nil, err
+		}
+		if cert, err = ca.Issue(cfg.TLS.Hosts); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   clientAuth,
+	}
+
+	switch {
+	case cfg.TLS.ClientCA != "":
+		pemBytes, err := os.ReadFile(cfg.TLS.ClientCA)
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading TLS client CA: %w", err)
+		}
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(pemBytes) {
+			return nil, nil, fmt.Errorf("no certificates found in %s", cfg.TLS.ClientCA)
+		}
+	case clientAuth == tls.RequireAndVerifyClientCert:
+		// The generated CA's key never leaves memory, so no client could
+		// hold a certificate it issued
+		return nil, nil, errors.New("TLS_CLIENT_AUTH=verify needs TLS_CLIENT_CA")
+	}
+
+	return tlsConfig, ca, nil
+}
+
+// clientAuthTypes maps TLS_CLIENT_AUTH values to handshake policies. Only
+// verify rejects untrusted certificates during the handshake; the others let
+// the echo report what was presented and whether it verifies.
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":    tls.NoClientCert,
+	"request": tls.RequestClientCert,
+	"require": tls.RequireAnyClientCert,
+	"verify":  tls.RequireAndVerifyClientCert,
+}
+
+func createServices(cfg *config.Server, server *http.Server, tlsConfig *tls.Config) ([]service, error) {
+	var services []service
+
+	// Wrap listeners with PROXY protocol decoding when enabled
+	wrap := func(ln net.Listener) net.Listener { return ln }
+	if cfg.Proxy.Enabled {
+		trusted, err := proxyproto.ParseCIDRs(cfg.Proxy.Trusted)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PROXY_TRUSTED: %w", err)
+		}
+		wrap = func(ln net.Listener) net.Listener { return proxyproto.NewListener(ln, trusted) }
+	}
+	// Raw capture sits outside PROXY decoding so it sees only HTTP, and stays
+	// off the TLS listener, where it would see ciphertext
+	plain := wrap
+	if cfg.RawCapture {
+		plain = func(ln net.Listener) net.Listener {
+			// net/http allows 4096 bytes beyond MaxHeaderBytes
+			return wire.NewListener(wrap(ln), cfg.MaxHeaderBytes+4096)
+		}
+	}
 
+	for _, a := range listenAddrs(cfg, server) {
+		addr, err := listener.Parse(a)
+		if err != nil {
+			closeServices(services)
+			return nil, err
+		}
+		ln, err := listener.Listen(addr, cfg.UnixSocketMode)
+		if err != nil {
+			closeServices(services)
+			return nil, fmt.Errorf("server failed to start: %w", err)
+		}
+		log.Printf("Echobox listening on %s", addr)
+		ln = plain(ln)
+		services = append(services, func(ctx context.Context) error {
+			return serveHTTP(ctx, server, cfg.ShutdownTimeout, func() error {
+				return server.Serve(ln)
+			})
+		})
+	}
+
+	if tlsConfig != nil {
+		ln, err := net.Listen("tcp", ":"+cfg.TLS.Port)
+		if err != nil {
+			closeServices(services)
+			return nil, fmt.Errorf("HTTPS server failed to start: %w", err)
+		}
+		log.Printf("Echobox listening on https://localhost:%s", cfg.TLS.Port)
+		ln = wrap(ln)
+		tlsServer := &http.Server{
+			Handler:     server.Handler,
+			TLSConfig:   tlsConfig,
+			ConnContext: server.ConnContext,
+		}
+		tuneServer(tlsServer, cfg)
+		services = append(services, func(ctx context.Context) error {
+			return serveHTTP(ctx, tlsServer, cfg.ShutdownTimeout, func() error {
+				return tlsServer.ServeTLS(ln, "", "")
+			})
+		})
+	}
+
+	opts := rawecho.Options{
+		Mode:    cfg.RawEcho.Mode,
+		HexDump: cfg.RawEcho.HexDump,
+		Delay:   time.Duration(cfg.RawEcho.DelayMs) * time.Millisecond,
+		Limit:   int64(cfg.RawEcho.Limit),
+	}
+
+	if cfg.RawEcho.TCPPort != "" {
+		ln, err := net.Listen("tcp", ":"+cfg.RawEcho.TCPPort)
+		if err != nil {
+			closeServices(services)
+			return nil, fmt.Errorf("TCP echo failed to start: %w", err)
+		}
+		log.Printf("TCP echo listening on %s", ln.Addr())
+		services = append(services, func(ctx context.Context) error {
+			return rawecho.ServeTCP(ctx, ln, opts)
+		})
+	}
+
+	if cfg.RawEcho.UDPPort != "" {
+		pc, err := net.ListenPacket("udp", ":"+cfg.RawEcho.UDPPort)
+		if err != nil {
+			closeServices(services)
+			return nil, fmt.Errorf("UDP echo failed to start: %w", err)
+		}
+		log.Printf("UDP echo listening on %s", pc.LocalAddr())
+		services = append(services, func(ctx context.Context) error {
+			return rawecho.ServeUDP(ctx, pc, opts)
+		})
+	}
+
+	return services, nil
+}
+
+// closeServices releases the listeners of services that were created but
+// never run.
+func closeServices(services []service) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, svc := range services {
+		svc(ctx)
+	}
+}
+
+// serveHTTP runs serve until ctx is cancelled, then shuts the server down
+// gracefully, giving in-flight requests up to shutdownTimeout to finish.
+func serveHTTP(ctx context.Context, server *http.Server, shutdownTimeout time.Duration, serve func() error) error {
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- serve()
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+		<-errChan
+		return nil
+	case err := <-errChan:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}
+}
+
+// shutdown controls how runServer drains. Health is marked not ready and
+// every listener keeps serving for delay, so load balancers stop routing to
+// the server before it closes; in-flight requests then get timeout to finish.
+type shutdown struct {
+	health  *health.State
+	delay   time.Duration
+	timeout time.Duration
+}
+
+func runServer(ctx context.Context, server *http.Server, sd shutdown, services ...service) error {
 	// Channel to capture server errors
 	errChan := make(chan error, 1)
 
+	// Without an address the server only runs on listeners owned by services
+	stopped := make(chan struct{})
+	if server.Addr == "" {
+		var once sync.Once
+		server.RegisterOnShutdown(func() { once.Do(func() { close(stopped) }) })
+	} else {
+		log.Printf("Echobox listening on http://localhost:%s", server.Addr[1:])
+	}
+
 	// Start server in background
 	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if server.Addr == "" {
+			<-stopped
+			errChan <- nil
+		} else if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			errChan <- fmt.Errorf("server failed to start: %w", err)
 		} else {
 			errChan <- nil
 		}
 	}()
 
-	// Wait for context cancellation or server error
+	// Start additional services, sharing a context cancelled once draining
+	// is over rather than when ctx is
+	serviceCtx, cancelServices := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelServices()
+
+	serviceErrs := make(chan error, len(services))
+	for _, svc := range services {
+		go func() {
+			serviceErrs <- svc(serviceCtx)
+		}()
+	}
+
+	pending := len(services)
+	var err error
+
+	// Wait for context cancellation, server error or a failing service
 	select {
 	case <-ctx.Done():
-		// Context cancelled, shutdown server gracefully
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		// Context cancelled, drain and shutdown server gracefully
+		if sd.health != nil {
+			sd.health.SetReady(false)
+		}
+		if sd.delay > 0 {
+			log.Printf("Draining for %v before shutdown", sd.delay)
+			time.Sleep(sd.delay)
+		}
+		// Services are cancelled below, once in-flight requests are done
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), sd.timeout)
 		defer cancel()
 		server.Shutdown(shutdownCtx)
 		// Return any error from ListenAndServe
-		return <-errChan
-	case err := <-errChan:
+		err = <-errChan
+	case err = <-errChan:
 		// Server stopped (either error or closed)
-		return err
+	case err = <-serviceErrs:
+		// A service stopped on its own, take the server down with it
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), sd.timeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+		<-errChan
+		pending--
+	}
+
+	// Stop the remaining services and wait for them
+	cancelServices()
+	for ; pending > 0; pending-- {
+		if svcErr := <-serviceErrs; err == nil {
+			err = svcErr
+		}
 	}
+
+	return err
 }
 
 func main() {
-	server := createServer()
-
-	if err := runServer(context.Background(), server); err != nil {
-		log.Fatalf("%v", err)
-	}
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
 }
 
 func init() {
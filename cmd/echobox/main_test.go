@@ -1,8 +1,16 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -10,11 +18,28 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/Elagoht/echobox/internal/certs"
+	"github.com/Elagoht/echobox/internal/config"
+	"github.com/Elagoht/echobox/internal/handler"
+	"github.com/Elagoht/echobox/internal/health"
+	"github.com/Elagoht/echobox/internal/router"
+	"golang.org/x/net/http2"
 )
 
+// newTestServer calls createServer, failing the test on an error.
+func newTestServer(t *testing.T, cfg *config.Server, opts ...router.Option) *http.Server {
+	t.Helper()
+	server, err := createServer(cfg, opts...)
+	if err != nil {
+		t.Fatalf("createServer() error = %v", err)
+	}
+	return server
+}
+
 func TestCreateServer(t *testing.T) {
 	// Test with default config
-	server := createServer()
+	server := newTestServer(t, config.Load())
 
 	if server == nil {
 		t.Fatal("createServer() returned nil")
@@ -48,7 +73,7 @@ func TestCreateServer(t *testing.T) {
 	os.Setenv("READ_TIMEOUT", "10")
 	os.Setenv("WRITE_TIMEOUT", "20")
 
-	server = createServer()
+	server = newTestServer(t, config.Load())
 
 	if server.Addr != ":9999" {
 		t.Errorf("createServer() Addr = %v, want :9999", server.Addr)
@@ -66,7 +91,7 @@ func TestCreateServer(t *testing.T) {
 }
 
 func TestCreateServerHandler(t *testing.T) {
-	server := createServer()
+	server := newTestServer(t, config.Load())
 
 	// Test that the handler works
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -89,7 +114,7 @@ func TestRunServer(t *testing.T) {
 	defer os.Setenv("PORT", oldPort)
 
 	os.Setenv("PORT", "5872")
-	server := createServer()
+	server := newTestServer(t, config.Load())
 
 	// Start server in background
 	ctx, cancel := context.WithCancel(context.Background())
@@ -97,7 +122,7 @@ func TestRunServer(t *testing.T) {
 
 	errChan := make(chan error, 1)
 	go func() {
-		errChan <- runServer(ctx, server)
+		errChan <- runServer(ctx, server, shutdown{timeout: config.DefaultShutdownTimeout})
 	}()
 
 	// Give server time to start
@@ -134,11 +159,11 @@ func TestRunServer_StartError(t *testing.T) {
 	// We'll create a server with an invalid address
 	server := &http.Server{
 		Addr:    ":invalid",
-		Handler: createServer().Handler,
+		Handler: newTestServer(t, config.Load()).Handler,
 	}
 
 	ctx := context.Background()
-	err := runServer(ctx, server)
+	err := runServer(ctx, server, shutdown{timeout: config.DefaultShutdownTimeout})
 
 	// Should return an error (not panic)
 	if err == nil {
@@ -155,7 +180,7 @@ func TestRunServer_Shutdown(t *testing.T) {
 	defer os.Setenv("PORT", oldPort)
 
 	os.Setenv("PORT", "5874")
-	server := createServer()
+	server := newTestServer(t, config.Load())
 
 	// Start server and immediately shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -163,7 +188,7 @@ func TestRunServer_Shutdown(t *testing.T) {
 	// Start server in background
 	errChan := make(chan error, 1)
 	go func() {
-		errChan <- runServer(ctx, server)
+		errChan <- runServer(ctx, server, shutdown{timeout: config.DefaultShutdownTimeout})
 	}()
 
 	// Give server time to start
@@ -183,11 +208,988 @@ func TestRunServer_Shutdown(t *testing.T) {
 	}
 }
 
+func TestRunServer_ShutdownDelay(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping shutdown test in short mode")
+	}
+
+	cfg := config.Load()
+	cfg.Port = "5888"
+	state := health.New()
+	server := newTestServer(t, cfg, router.WithHealth(state))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- runServer(ctx, server, shutdown{health: state, delay: 500 * time.Millisecond, timeout: config.DefaultShutdownTimeout})
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	// Still serving while draining, but no longer ready
+	resp, err := http.Get("http://localhost:5888/_ready")
+	if err != nil {
+		t.Fatalf("GET /_ready during drain error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("GET /_ready during drain status = %d, want 503", resp.StatusCode)
+	}
+
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Errorf("runServer() error = %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Server did not stop after draining")
+	}
+}
+
+func TestCreateServices(t *testing.T) {
+	cfg := config.Load()
+
+	services, err := createServices(cfg, newTestServer(t, cfg), nil)
+	if err != nil {
+		t.Fatalf("createServices() error = %v", err)
+	}
+	if len(services) != 0 {
+		t.Errorf("createServices() = %d services, want 0 without echo ports", len(services))
+	}
+
+	cfg.RawEcho.TCPPort = "0"
+	cfg.RawEcho.UDPPort = "0"
+
+	services, err = createServices(cfg, newTestServer(t, cfg), nil)
+	if err != nil {
+		t.Fatalf("createServices() error = %v", err)
+	}
+	if len(services) != 2 {
+		t.Errorf("createServices() = %d services, want 2", len(services))
+	}
+	closeServices(services)
+}
+
+func TestCreateServices_ListenError(t *testing.T) {
+	cfg := config.Load()
+	cfg.RawEcho.TCPPort = "invalid"
+
+	if _, err := createServices(cfg, newTestServer(t, cfg), nil); err == nil {
+		t.Error("createServices() error = nil, want error for invalid TCP port")
+	}
+}
+
+func TestRunServer_WithServices(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping server test in short mode")
+	}
+
+	oldPort := os.Getenv("PORT")
+	defer os.Setenv("PORT", oldPort)
+
+	os.Setenv("PORT", "5875")
+	cfg := config.Load()
+	cfg.RawEcho.TCPPort = "5876"
+
+	services, err := createServices(cfg, newTestServer(t, cfg), nil)
+	if err != nil {
+		t.Fatalf("createServices() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	server := newTestServer(t, cfg)
+	go func() {
+		errChan <- runServer(ctx, server, shutdown{timeout: config.DefaultShutdownTimeout}, services...)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", "localhost:5876")
+	if err != nil {
+		t.Fatalf("Failed to connect to TCP echo: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Errorf("TCP echo returned %q, %v, want ping", buf, err)
+	}
+
+	cancel()
+
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Errorf("Unexpected error from runServer: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Server did not stop within timeout")
+	}
+}
+
+func TestRunServer_ServiceError(t *testing.T) {
+	oldPort := os.Getenv("PORT")
+	defer os.Setenv("PORT", oldPort)
+
+	os.Setenv("PORT", "5877")
+	failing := func(ctx context.Context) error {
+		return errors.New("service failed")
+	}
+
+	errChan := make(chan error, 1)
+	server := newTestServer(t, config.Load())
+	go func() {
+		errChan <- runServer(context.Background(), server, shutdown{timeout: config.DefaultShutdownTimeout}, failing)
+	}()
+
+	select {
+	case err := <-errChan:
+		if err == nil || err.Error() != "service failed" {
+			t.Errorf("runServer() error = %v, want service failed", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Server did not stop after service failure")
+	}
+}
+
+func TestCreateTLSConfig(t *testing.T) {
+	cfg := config.Load()
+
+	tlsConfig, ca, err := createTLSConfig(cfg)
+	if err != nil || tlsConfig != nil || ca != nil {
+		t.Errorf("createTLSConfig() = %v, %v, %v, want nothing when TLS is disabled", tlsConfig, ca, err)
+	}
+
+	cfg.TLS.Port = "0"
+	tlsConfig, ca, err = createTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("createTLSConfig() error = %v", err)
+	}
+	if ca == nil || len(tlsConfig.Certificates) != 1 {
+		t.Fatal("createTLSConfig() did not generate a CA and certificate")
+	}
+
+	// Round-trip the generated pair through files
+	dir := t.TempDir()
+	cfg.TLS.CertFile = dir + "/cert.pem"
+	cfg.TLS.KeyFile = dir + "/key.pem"
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(tlsConfig.Certificates[0].PrivateKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	os.WriteFile(cfg.TLS.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsConfig.Certificates[0].Certificate[0]}), 0o600)
+	os.WriteFile(cfg.TLS.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600)
+
+	tlsConfig, ca, err = createTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("createTLSConfig() from files error = %v", err)
+	}
+	if ca != nil || len(tlsConfig.Certificates) != 1 {
+		t.Error("createTLSConfig() from files generated a CA")
+	}
+
+	cfg.TLS.KeyFile = ""
+	if _, _, err := createTLSConfig(cfg); err == nil {
+		t.Error("createTLSConfig() error = nil, want error when only the certificate file is set")
+	}
+
+	cfg.TLS.KeyFile = dir + "/missing.pem"
+	if _, _, err := createTLSConfig(cfg); err == nil {
+		t.Error("createTLSConfig() error = nil, want error for missing key file")
+	}
+}
+
+func TestCreateTLSConfig_ClientAuth(t *testing.T) {
+	cfg := config.Load()
+	cfg.TLS.Port = "0"
+	clientCA, _ := writeClientCA(t)
+
+	tests := []struct {
+		clientAuth string
+		clientCA   string
+		want       tls.ClientAuthType
+	}{
+		{"none", "", tls.NoClientCert},
+		{"request", "", tls.RequestClientCert},
+		{"require", "", tls.RequireAnyClientCert},
+		{"verify", clientCA, tls.RequireAndVerifyClientCert},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.clientAuth, func(t *testing.T) {
+			cfg.TLS.ClientAuth = tt.clientAuth
+			cfg.TLS.ClientCA = tt.clientCA
+			tlsConfig, _, err := createTLSConfig(cfg)
+			if err != nil {
+				t.Fatalf("createTLSConfig() error = %v", err)
+			}
+			if tlsConfig.ClientAuth != tt.want {
+				t.Errorf("createTLSConfig() ClientAuth = %v, want %v", tlsConfig.ClientAuth, tt.want)
+			}
+			if (tlsConfig.ClientCAs != nil) != (tt.clientCA != "") {
+				t.Errorf("createTLSConfig() ClientCAs = %v, want a pool only from TLS_CLIENT_CA", tlsConfig.ClientCAs)
+			}
+		})
+	}
+
+	cfg.TLS.ClientAuth = "verify"
+	cfg.TLS.ClientCA = ""
+	if _, _, err := createTLSConfig(cfg); err == nil {
+		t.Error("createTLSConfig() error = nil, want error for verify without a client CA")
+	}
+
+	cfg.TLS.ClientAuth = "sometimes"
+	if _, _, err := createTLSConfig(cfg); err == nil {
+		t.Error("createTLSConfig() error = nil, want error for unknown client auth")
+	}
+
+	cfg.TLS.ClientAuth = "request"
+	cfg.TLS.ClientCA = t.TempDir() + "/missing.pem"
+	if _, _, err := createTLSConfig(cfg); err == nil {
+		t.Error("createTLSConfig() error = nil, want error for missing client CA")
+	}
+}
+
+// writeClientCA creates an authority for client certificates and writes its
+// certificate to a PEM file for TLS_CLIENT_CA.
+func writeClientCA(t *testing.T) (string, *certs.Authority) {
+	t.Helper()
+	ca, err := certs.NewAuthority()
+	if err != nil {
+		t.Fatalf("NewAuthority() error = %v", err)
+	}
+	path := t.TempDir() + "/clients.pem"
+	if err := os.WriteFile(path, ca.CertPEM(), 0o600); err != nil {
+		t.Fatalf("Failed to write client CA: %v", err)
+	}
+	return path, ca
+}
+
+func TestRunServer_MutualTLS(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping server test in short mode")
+	}
+
+	oldPort := os.Getenv("PORT")
+	defer os.Setenv("PORT", oldPort)
+
+	os.Setenv("PORT", "5880")
+	cfg := config.Load()
+	cfg.TLS.Port = "5881"
+	cfg.TLS.ClientAuth = "require"
+	var clients *certs.Authority
+	cfg.TLS.ClientCA, clients = writeClientCA(t)
+
+	tlsConfig, ca, err := createTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("createTLSConfig() error = %v", err)
+	}
+	server := newTestServer(t, cfg, router.WithCA(ca.CertPEM()), router.WithClientCAs(tlsConfig.ClientCAs))
+	services, err := createServices(cfg, server, tlsConfig)
+	if err != nil {
+		t.Fatalf("createServices() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- runServer(ctx, server, shutdown{timeout: config.DefaultShutdownTimeout}, services...)
+	}()
+	defer func() {
+		cancel()
+		<-errChan
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	clientCert, err := clients.IssueClient("client-a")
+	if err != nil {
+		t.Fatalf("IssueClient() error = %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{clientCert},
+	}}}
+	resp, err := client.Get("https://localhost:5881/")
+	if err != nil {
+		t.Fatalf("HTTPS request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var echo handler.EchoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&echo); err != nil {
+		t.Fatalf("Failed to decode echo: %v", err)
+	}
+	if echo.TLS == nil || len(echo.TLS.ClientCertificates) == 0 {
+		t.Fatalf("Echo TLS = %+v, want client certificates", echo.TLS)
+	}
+	if echo.TLS.ClientCertificates[0].Subject != "CN=client-a,O=Echobox" || !echo.TLS.ClientVerified {
+		t.Errorf("Echo TLS = %+v, want verified client-a", echo.TLS)
+	}
+
+	// Without a client certificate the handshake must fail
+	noCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	if resp, err := noCert.Get("https://localhost:5881/"); err == nil {
+		resp.Body.Close()
+		t.Error("Request without client certificate succeeded, want handshake failure")
+	}
+}
+
+func TestRunServer_TLS(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping server test in short mode")
+	}
+
+	oldPort := os.Getenv("PORT")
+	defer os.Setenv("PORT", oldPort)
+
+	os.Setenv("PORT", "5878")
+	cfg := config.Load()
+	cfg.TLS.Port = "5879"
+
+	tlsConfig, ca, err := createTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("createTLSConfig() error = %v", err)
+	}
+	server := newTestServer(t, cfg, router.WithCA(ca.CertPEM()))
+	services, err := createServices(cfg, server, tlsConfig)
+	if err != nil {
+		t.Fatalf("createServices() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- runServer(ctx, server, shutdown{timeout: config.DefaultShutdownTimeout}, services...)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Download the CA over plain HTTP, then use it to trust the HTTPS listener
+	resp, err := http.Get("http://localhost:5878/ca.pem")
+	if err != nil {
+		t.Fatalf("Failed to download CA: %v", err)
+	}
+	caPEM, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		t.Fatalf("Downloaded CA is not valid PEM: %q", caPEM)
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err = client.Get("https://localhost:5879/")
+	if err != nil {
+		t.Fatalf("HTTPS request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("HTTPS status = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+
+	cancel()
+
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Errorf("Unexpected error from runServer: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Server did not stop within timeout")
+	}
+}
+
+func TestRunServer_HTTP2(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping server test in short mode")
+	}
+
+	oldPort := os.Getenv("PORT")
+	defer os.Setenv("PORT", oldPort)
+
+	os.Setenv("PORT", "5882")
+	cfg := config.Load()
+	cfg.TLS.Port = "5883"
+
+	tlsConfig, ca, err := createTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("createTLSConfig() error = %v", err)
+	}
+	server := newTestServer(t, cfg)
+	services, err := createServices(cfg, server, tlsConfig)
+	if err != nil {
+		t.Fatalf("createServices() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- runServer(ctx, server, shutdown{timeout: config.DefaultShutdownTimeout}, services...)
+	}()
+	defer func() {
+		cancel()
+		<-errChan
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	echo := func(t *testing.T, client *http.Client, url string) handler.EchoResponse {
+		t.Helper()
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var echo handler.EchoResponse
+		if err := json.NewDecoder(resp.Body).Decode(&echo); err != nil {
+			t.Fatalf("Failed to decode echo: %v", err)
+		}
+		return echo
+	}
+
+	t.Run("h2c prior knowledge", func(t *testing.T) {
+		protocols := new(http.Protocols)
+		protocols.SetUnencryptedHTTP2(true)
+		client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+
+		if got := echo(t, client, "http://localhost:5882/"); got.Proto != "HTTP/2.0" {
+			t.Errorf("Echo proto = %v, want HTTP/2.0", got.Proto)
+		}
+	})
+
+	t.Run("h2c upgrade", func(t *testing.T) {
+		conn, err := net.Dial("tcp", "localhost:5882")
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade, HTTP2-Settings\r\n" +
+			"Upgrade: h2c\r\nHTTP2-Settings: AAMAAABkAAQCAAAAAAIAAAAA\r\n\r\n"))
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		status := make([]byte, len("HTTP/1.1 101"))
+		if _, err := io.ReadFull(conn, status); err != nil || string(status) != "HTTP/1.1 101" {
+			t.Errorf("Upgrade response = %q, %v, want 101 Switching Protocols", status, err)
+		}
+	})
+
+	t.Run("HTTP/1.1 still served", func(t *testing.T) {
+		if got := echo(t, http.DefaultClient, "http://localhost:5882/"); got.Proto != "HTTP/1.1" {
+			t.Errorf("Echo proto = %v, want HTTP/1.1", got.Proto)
+		}
+	})
+
+	t.Run("h2 over TLS", func(t *testing.T) {
+		pool := x509.NewCertPool()
+		pool.AddCert(ca.Cert)
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: pool},
+			ForceAttemptHTTP2: true,
+		}}
+
+		got := echo(t, client, "https://localhost:5883/")
+		if got.Proto != "HTTP/2.0" {
+			t.Errorf("Echo proto = %v, want HTTP/2.0", got.Proto)
+		}
+		if got.TLS == nil || got.TLS.NegotiatedProtocol != "h2" {
+			t.Errorf("Echo tls = %+v, want ALPN h2", got.TLS)
+		}
+	})
+}
+
+func TestCreateServer_H2CGoAway(t *testing.T) {
+	cfg := config.Load()
+	cfg.AccessLog.Enabled = false
+	cfg.IdleTimeout = 100 * time.Millisecond
+	server := newTestServer(t, cfg)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go server.Serve(ln)
+	defer server.Close()
+
+	// goAway opens a prior knowledge connection and waits for the GOAWAY
+	// that closing it sends
+	goAway := func(t *testing.T, close func()) {
+		t.Helper()
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer conn.Close()
+		conn.Write([]byte(http2.ClientPreface))
+		framer := http2.NewFramer(conn, conn)
+		framer.WriteSettings()
+		if _, err := framer.ReadFrame(); err != nil {
+			t.Fatalf("Failed to read server settings: %v", err)
+		}
+
+		close()
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for {
+			frame, err := framer.ReadFrame()
+			if err != nil {
+				t.Fatalf("ReadFrame() error = %v, want GOAWAY", err)
+			}
+			if _, ok := frame.(*http2.GoAwayFrame); ok {
+				return
+			}
+		}
+	}
+
+	t.Run("idle timeout", func(t *testing.T) {
+		goAway(t, func() {})
+	})
+	t.Run("shutdown", func(t *testing.T) {
+		goAway(t, func() { server.Shutdown(context.Background()) })
+	})
+}
+
+func TestCreateServer_H2CDisabled(t *testing.T) {
+	cfg := config.Load()
+	cfg.H2C = false
+
+	server := newTestServer(t, cfg)
+	if name := fmt.Sprintf("%T", server.Handler); strings.Contains(name, "h2c") {
+		t.Errorf("createServer() handler = %s, want no h2c wrapper", name)
+	}
+}
+
+func TestCreateServer_AccessLog(t *testing.T) {
+	cfg := config.Load()
+	cfg.H2C = false
+
+	cfg.AccessLog.Enabled = true
+	if _, ok := newTestServer(t, cfg).Handler.(*http.ServeMux); ok {
+		t.Error("createServer() handler is the bare router, want the access log middleware")
+	}
+}
+
+func TestCreateServer_Metrics(t *testing.T) {
+	cfg := config.Load()
+	cfg.H2C = false
+	cfg.AccessLog.Enabled = false
+
+	tests := []struct {
+		enabled    bool
+		wantStatus int
+		wantBody   string
+	}{
+		{true, http.StatusOK, "echobox_http_requests_total"},
+		{false, http.StatusOK, `"path":"/metrics"`},
+	}
+
+	for _, tt := range tests {
+		cfg.Metrics = tt.enabled
+		handler := newTestServer(t, cfg).Handler
+
+		// Prime a request so the counters have a series
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/headers", nil))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantBody) {
+			t.Errorf("Metrics %v: GET /metrics = %d %q, want %d containing %q", tt.enabled, w.Code, w.Body.String(), tt.wantStatus, tt.wantBody)
+		}
+	}
+}
+
+func TestCreateServer_Admin(t *testing.T) {
+	cfg := config.Default()
+	cfg.H2C = false
+	cfg.AccessLog.Enabled = false
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		wantHealth int
+	}{
+		{"default", cfg.Admin.Enabled, http.StatusOK},
+		{"enabled", true, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Admin.Enabled = tt.enabled
+			handler := newTestServer(t, cfg, router.WithHealth(health.New())).Handler
+
+			req := httptest.NewRequest(http.MethodPut, "/_admin/health", strings.NewReader(`{"mode":"unhealthy"}`))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/_health", nil))
+			if w.Code != tt.wantHealth {
+				t.Errorf("GET /_health after PUT /_admin/health = %d, want %d", w.Code, tt.wantHealth)
+			}
+		})
+	}
+}
+
+func TestCreateServer_CORS(t *testing.T) {
+	cfg := config.Load()
+	cfg.H2C = false
+	cfg.AccessLog.Enabled = false
+
+	preflight := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/", nil)
+		req.Header.Set("Origin", "https://app.test")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+		w := httptest.NewRecorder()
+		newTestServer(t, cfg).Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := preflight(); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("preflight without CORS allowed origin %q, want nothing", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	cfg.CORS.Enabled = true
+	w := preflight()
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "*" || !strings.Contains(w.Body.String(), `"allowed":true`) {
+		t.Errorf("preflight with CORS = %d %v %s, want it allowed", w.Code, w.Header(), w.Body.String())
+	}
+
+	cfg.CORS.Origins = []string{"/(/"}
+	if _, err := createServer(cfg); err == nil {
+		t.Error("createServer() with an invalid CORS origin error = nil, want the origin reported")
+	}
+}
+
+func TestRunServer_MultipleListeners(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping server test in short mode")
+	}
+
+	socket := t.TempDir() + "/echobox.sock"
+	cfg := config.Load()
+	cfg.Listen = []string{"127.0.0.1:5884", "tcp://[::1]:5885", "unix://" + socket}
+	cfg.UnixSocketMode = 0o600
+
+	server := newTestServer(t, cfg)
+	if server.Addr != "" {
+		t.Errorf("createServer() Addr = %q, want empty when listeners are configured", server.Addr)
+	}
+
+	services, err := createServices(cfg, server, nil)
+	if err != nil {
+		t.Fatalf("createServices() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- runServer(ctx, server, shutdown{timeout: config.DefaultShutdownTimeout}, services...)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("Unix socket stat = %v, %v, want mode 0600", info, err)
+	}
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+
+	clients := []struct {
+		name   string
+		client *http.Client
+		url    string
+	}{
+		{"IPv4 interface", http.DefaultClient, "http://127.0.0.1:5884/"},
+		{"IPv6 loopback", http.DefaultClient, "http://[::1]:5885/"},
+		{"unix socket", unixClient, "http://echobox/"},
+	}
+
+	for _, c := range clients {
+		t.Run(c.name, func(t *testing.T) {
+			resp, err := c.client.Get(c.url)
+			if err != nil {
+				if strings.Contains(c.url, "::1") {
+					t.Skipf("IPv6 unavailable: %v", err)
+				}
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("Status = %v, want %v", resp.StatusCode, http.StatusOK)
+			}
+		})
+	}
+
+	cancel()
+
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Errorf("Unexpected error from runServer: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Server did not stop within timeout")
+	}
+
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("Unix socket still exists after shutdown: %v", err)
+	}
+}
+
+func TestCreateServices_InvalidListen(t *testing.T) {
+	cfg := config.Load()
+
+	for _, addr := range []string{"udp://:9000", "tcp://256.0.0.1:99999"} {
+		cfg.Listen = []string{addr}
+		if _, err := createServices(cfg, newTestServer(t, cfg), nil); err == nil {
+			t.Errorf("createServices() error = nil for listen address %q", addr)
+		}
+	}
+}
+
+func TestRunServer_ProxyProtocol(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping server test in short mode")
+	}
+
+	oldPort := os.Getenv("PORT")
+	defer os.Setenv("PORT", oldPort)
+
+	os.Setenv("PORT", "5886")
+	cfg := config.Load()
+	cfg.Proxy.Enabled = true
+	cfg.Proxy.Trusted = []string{"127.0.0.1"}
+
+	server := newTestServer(t, cfg)
+	services, err := createServices(cfg, server, nil)
+	if err != nil {
+		t.Fatalf("createServices() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- runServer(ctx, server, shutdown{timeout: config.DefaultShutdownTimeout}, services...)
+	}()
+	defer func() {
+		cancel()
+		<-errChan
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", "127.0.0.1:5886")
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n" +
+		"GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var echo handler.EchoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&echo); err != nil {
+		t.Fatalf("Failed to decode echo: %v", err)
+	}
+	if echo.Proxy == nil || echo.Proxy.Source != "192.0.2.1:56324" || echo.Proxy.Destination != "198.51.100.1:443" {
+		t.Errorf("Echo proxy = %+v, want decoded v1 header", echo.Proxy)
+	}
+}
+
+func TestCreateServices_InvalidProxyTrusted(t *testing.T) {
+	cfg := config.Load()
+	cfg.Proxy.Enabled = true
+	cfg.Proxy.Trusted = []string{"not-a-network"}
+
+	if _, err := createServices(cfg, newTestServer(t, cfg), nil); err == nil {
+		t.Error("createServices() error = nil, want error for invalid trusted network")
+	}
+}
+
+func TestRunServer_RawCapture(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping server test in short mode")
+	}
+
+	cfg := config.Load()
+	cfg.Port = "5889"
+	cfg.RawCapture = true
+	cfg.Proxy.Enabled = true
+	cfg.Proxy.Trusted = []string{"127.0.0.1"}
+
+	server := newTestServer(t, cfg)
+	services, err := createServices(cfg, server, nil)
+	if err != nil {
+		t.Fatalf("createServices() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- runServer(ctx, server, shutdown{timeout: config.DefaultShutdownTimeout}, services...)
+	}()
+	defer func() {
+		cancel()
+		<-errChan
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", "127.0.0.1:5889")
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+
+	// The PROXY header is not part of the capture, and each keep-alive
+	// request gets its own
+	conn.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"))
+	heads := []string{
+		"POST /raw HTTP/1.1\r\nhost: localhost\r\nx-lower: 1\r\nContent-Length: 4\r\nX-UPPER: 2\r\n\r\n",
+		"GET /raw?a=b HTTP/1.1\r\nHost: localhost\r\nzz-Last: z\r\naa-First: a\r\n\r\n",
+	}
+	for i, head := range heads {
+		conn.Write([]byte(head))
+		if i == 0 {
+			conn.Write([]byte("body"))
+		}
+
+		resp, err := http.ReadResponse(br, nil)
+		if err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		got, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(got) != head {
+			t.Errorf("/raw = %q, want %q", got, head)
+		}
+	}
+}
+
+func TestCreateServer_Tuning(t *testing.T) {
+	cfg := config.Load()
+	cfg.ReadHeaderTimeout = 2 * time.Second
+	cfg.IdleTimeout = time.Minute
+	cfg.MaxHeaderBytes = 4096
+
+	server := newTestServer(t, cfg)
+	if server.ReadHeaderTimeout != 2*time.Second || server.IdleTimeout != time.Minute {
+		t.Errorf("createServer() header/idle timeouts = %v/%v, want 2s/1m", server.ReadHeaderTimeout, server.IdleTimeout)
+	}
+	if server.MaxHeaderBytes != 4096 {
+		t.Errorf("createServer() MaxHeaderBytes = %v, want 4096", server.MaxHeaderBytes)
+	}
+}
+
+func TestRunServer_Tuning(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping server test in short mode")
+	}
+
+	oldPort := os.Getenv("PORT")
+	defer os.Setenv("PORT", oldPort)
+
+	os.Setenv("PORT", "5887")
+	cfg := config.Load()
+	cfg.KeepAlive = false
+	cfg.MaxHeaderBytes = 1024
+
+	server := newTestServer(t, cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- runServer(ctx, server, shutdown{timeout: cfg.ShutdownTimeout})
+	}()
+	defer func() {
+		cancel()
+		<-errChan
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	resp, err := http.Get("http://localhost:5887/")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if !resp.Close {
+		t.Error("Response keeps the connection open, want Connection: close with keep-alive disabled")
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost:5887/", nil)
+	req.Header.Set("X-Large", strings.Repeat("a", 8192))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("Status = %v, want %v for headers over the limit", resp.StatusCode, http.StatusRequestHeaderFieldsTooLarge)
+	}
+}
+
+func TestServeHTTP_ShutdownTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- serveHTTP(ctx, server, 100*time.Millisecond, func() error {
+			return server.Serve(ln)
+		})
+	}()
+
+	go http.Get("http://" + ln.Addr().String() + "/")
+	<-started
+
+	start := time.Now()
+	cancel()
+	select {
+	case <-errChan:
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+			t.Errorf("serveHTTP() returned after %v, want it to wait for the in-flight request", elapsed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("serveHTTP() did not give up after the shutdown timeout")
+	}
+}
+
 func TestMainFunc_StartError(t *testing.T) {
 	// Test main() when server fails to start
 	// We'll use an invalid port to cause startup failure
 	if os.Getenv("TEST_MAIN_ERROR") == "1" {
 		os.Setenv("PORT", "invalid")
+		os.Args = []string{"echobox"}
 		main()
 		return
 	}
@@ -240,10 +1242,10 @@ func TestIntegration_AllEndpoints(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	server := createServer()
+	server := newTestServer(t, config.Load())
 	errChan := make(chan error, 1)
 	go func() {
-		errChan <- runServer(ctx, server)
+		errChan <- runServer(ctx, server, shutdown{timeout: config.DefaultShutdownTimeout})
 	}()
 
 	// Give server time to start
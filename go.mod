@@ -1,3 +1,17 @@
 module github.com/Elagoht/echobox
 
 go 1.25.0
+
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/fxamacker/cbor/v2 v2.9.4
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	golang.org/x/net v0.47.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/text v0.31.0 // indirect
+)
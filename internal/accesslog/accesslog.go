@@ -0,0 +1,203 @@
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Elagoht/echobox/internal/requestid"
+	"github.com/Elagoht/echobox/internal/response"
+)
+
+const (
+	FormatText     = "text"
+	FormatJSON     = "json"
+	FormatCombined = "combined"
+	FormatCommon   = "common"
+)
+
+const redacted = "[REDACTED]"
+
+type Options struct {
+	// Format is one of FormatText, FormatJSON, FormatCombined or
+	// FormatCommon. Unknown formats fall back to text.
+	Format string
+	// Level is the minimum level logged. Requests are logged at info, client
+	// errors at warn and server errors at error.
+	Level slog.Level
+	// Headers adds the request headers, with Redact ones masked.
+	Headers bool
+	Redact  []string
+	// Body adds up to Body bytes of the request body as the handler read it.
+	Body int
+	// Skip lists paths that are never logged, such as health probes.
+	Skip []string
+}
+
+// Logger writes one structured record per request through log/slog.
+type Logger struct {
+	logger *slog.Logger
+	opts   Options
+	redact map[string]bool
+	skip   map[string]bool
+}
+
+func New(w io.Writer, opts Options) *Logger {
+	redact := make(map[string]bool, len(opts.Redact))
+	for _, name := range opts.Redact {
+		redact[http.CanonicalHeaderKey(name)] = true
+	}
+	skip := make(map[string]bool, len(opts.Skip))
+	for _, path := range opts.Skip {
+		skip[path] = true
+	}
+	return &Logger{
+		logger: slog.New(NewHandler(w, opts.Format, opts.Level)),
+		opts:   opts,
+		redact: redact,
+		skip:   skip,
+	}
+}
+
+// NewHandler returns the slog handler for format. The Apache formats only
+// use the request attributes written by Logger.
+func NewHandler(w io.Writer, format string, level slog.Leveler) slog.Handler {
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case FormatJSON:
+		return slog.NewJSONHandler(w, handlerOpts)
+	case FormatCombined, FormatCommon:
+		return &apacheHandler{w: w, level: level, combined: format == FormatCombined, mu: &sync.Mutex{}}
+	default:
+		return slog.NewTextHandler(w, handlerOpts)
+	}
+}
+
+// ParseLevel accepts debug, info, warn or error, as slog spells them.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(s))
+	return level, err
+}
+
+func (l *Logger) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.skip[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rw := &response.Recorder{ResponseWriter: w}
+
+		var body *bodyCapture
+		if l.opts.Body > 0 && r.Body != nil {
+			body = &bodyCapture{ReadCloser: r.Body, limit: l.opts.Body}
+			r.Body = body
+		}
+
+		defer func() {
+			// Log requests whose handler aborted the connection too
+			l.log(r, rw, body, time.Since(start))
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func (l *Logger) log(r *http.Request, rw *response.Recorder, body *bodyCapture, latency time.Duration) {
+	status := rw.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	level := slog.LevelInfo
+	switch {
+	case status >= 500:
+		level = slog.LevelError
+	case status >= 400:
+		level = slog.LevelWarn
+	}
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("uri", r.RequestURI),
+		slog.String("proto", r.Proto),
+		slog.Int("status", status),
+		slog.Int64("bytes", rw.Bytes),
+		slog.Float64("latency_ms", float64(latency.Microseconds())/1000),
+		slog.String("remote_ip", remoteIP(r.RemoteAddr)),
+	}
+	id := requestid.FromContext(r.Context())
+	if id == "" {
+		id = r.Header.Get(requestid.Header)
+	}
+	if id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if user, _, ok := r.BasicAuth(); ok {
+		attrs = append(attrs, slog.String("user", user))
+	}
+	attrs = append(attrs,
+		slog.String("referer", r.Referer()),
+		slog.String("user_agent", r.UserAgent()),
+	)
+	if l.opts.Headers {
+		attrs = append(attrs, l.headerAttrs(r.Header))
+	}
+	if body != nil {
+		attrs = append(attrs, slog.String("body", body.buf.String()))
+	}
+
+	l.logger.LogAttrs(ctx, level, "request", attrs...)
+}
+
+func (l *Logger) headerAttrs(header http.Header) slog.Attr {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	attrs := make([]any, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(header[name], ", ")
+		if l.redact[http.CanonicalHeaderKey(name)] {
+			value = redacted
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return slog.Group("headers", attrs...)
+}
+
+func remoteIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// bodyCapture keeps the first limit bytes the handler reads from the body.
+type bodyCapture struct {
+	io.ReadCloser
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *bodyCapture) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := b.limit - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(n, room)])
+	}
+	return n, err
+}
@@ -0,0 +1,210 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func serve(l *Logger, h http.HandlerFunc, req *http.Request) {
+	l.Handler(h).ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestLogger_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, Options{Format: FormatJSON})
+
+	req := httptest.NewRequest(http.MethodPost, "/path?x=1", strings.NewReader("body"))
+	req.RemoteAddr = "192.0.2.1:4000"
+	req.Header.Set("X-Request-Id", "abc123")
+	req.Header.Set("User-Agent", "curl/8.0")
+	serve(l, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}, req)
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode log line %q: %v", buf.String(), err)
+	}
+
+	want := map[string]any{
+		"level":      "INFO",
+		"msg":        "request",
+		"method":     "POST",
+		"uri":        "/path?x=1",
+		"status":     float64(201),
+		"bytes":      float64(5),
+		"remote_ip":  "192.0.2.1",
+		"request_id": "abc123",
+		"user_agent": "curl/8.0",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("log %s = %v, want %v", k, got[k], v)
+		}
+	}
+	if _, ok := got["latency_ms"].(float64); !ok {
+		t.Errorf("log latency_ms = %v, want a number", got["latency_ms"])
+	}
+	if _, ok := got["headers"]; ok {
+		t.Error("log includes headers, want them only when enabled")
+	}
+}
+
+func TestLogger_Levels(t *testing.T) {
+	tests := []struct {
+		status    int
+		minLevel  slog.Level
+		wantLevel string
+	}{
+		{http.StatusOK, slog.LevelInfo, "level=INFO"},
+		{http.StatusNotFound, slog.LevelInfo, "level=WARN"},
+		{http.StatusBadGateway, slog.LevelInfo, "level=ERROR"},
+		{http.StatusOK, slog.LevelWarn, ""},
+		{http.StatusNotFound, slog.LevelError, ""},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		l := New(&buf, Options{Format: FormatText, Level: tt.minLevel})
+		serve(l, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		}, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if tt.wantLevel == "" {
+			if buf.Len() != 0 {
+				t.Errorf("status %d at minimum %v logged %q, want nothing", tt.status, tt.minLevel, buf.String())
+			}
+			continue
+		}
+		if !strings.Contains(buf.String(), tt.wantLevel) || !strings.Contains(buf.String(), "status="+strconv.Itoa(tt.status)) {
+			t.Errorf("status %d logged %q, want %s", tt.status, buf.String(), tt.wantLevel)
+		}
+	}
+}
+
+func TestLogger_Skip(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, Options{Skip: []string{"/_health"}})
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	serve(l, ok, httptest.NewRequest(http.MethodGet, "/_health", nil))
+	if buf.Len() != 0 {
+		t.Errorf("skipped path logged %q, want nothing", buf.String())
+	}
+
+	serve(l, ok, httptest.NewRequest(http.MethodGet, "/_health/more", nil))
+	if buf.Len() == 0 {
+		t.Error("other path logged nothing, want a record")
+	}
+}
+
+func TestLogger_HeadersAndBody(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, Options{Format: FormatJSON, Headers: true, Redact: []string{"authorization", "X-Secret"}, Body: 4})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("0123456789"))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("X-Secret", "hunter2")
+	req.Header.Add("Accept", "text/plain")
+	req.Header.Add("Accept", "application/json")
+	serve(l, func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+	}, req)
+
+	var got struct {
+		Headers map[string]string `json:"headers"`
+		Body    string            `json:"body"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode log line %q: %v", buf.String(), err)
+	}
+
+	if got.Headers["Authorization"] != redacted || got.Headers["X-Secret"] != redacted {
+		t.Errorf("log headers = %v, want sensitive headers redacted", got.Headers)
+	}
+	if got.Headers["Accept"] != "text/plain, application/json" {
+		t.Errorf("log Accept = %q, want both values", got.Headers["Accept"])
+	}
+	if got.Body != "0123" {
+		t.Errorf("log body = %q, want the first 4 bytes", got.Body)
+	}
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Error("log leaks a redacted header value")
+	}
+}
+
+func TestLogger_Apache(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{FormatCommon, `^192\.0\.2\.1 - alice \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /a\?b=c HTTP/1\.1" 200 5\n$`},
+		{FormatCombined, `^192\.0\.2\.1 - alice \[.+\] "GET /a\?b=c HTTP/1\.1" 200 5 "http://ref\.example/" "test-agent"\n$`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := New(&buf, Options{Format: tt.format})
+
+			req := httptest.NewRequest(http.MethodGet, "/a?b=c", nil)
+			req.RemoteAddr = "192.0.2.1:4000"
+			req.SetBasicAuth("alice", "secret")
+			req.Header.Set("Referer", "http://ref.example/")
+			req.Header.Set("User-Agent", "test-agent")
+			serve(l, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("hello"))
+			}, req)
+
+			if !regexp.MustCompile(tt.want).MatchString(buf.String()) {
+				t.Errorf("log = %q, want match for %s", buf.String(), tt.want)
+			}
+		})
+	}
+
+	// Missing fields and empty responses are written as "-"
+	var buf bytes.Buffer
+	l := New(&buf, Options{Format: FormatCombined})
+	serve(l, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(buf.String(), `" 204 - "-" "-"`) {
+		t.Errorf("log = %q, want - for missing size, referer and agent", buf.String())
+	}
+}
+
+func TestLogger_Panic(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, Options{Format: FormatText})
+
+	func() {
+		defer func() { recover() }()
+		serve(l, func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}, httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	if !strings.Contains(buf.String(), "msg=request") {
+		t.Errorf("log = %q, want aborted requests logged", buf.String())
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for input, want := range map[string]slog.Level{"debug": slog.LevelDebug, "info": slog.LevelInfo, "WARN": slog.LevelWarn, "error": slog.LevelError} {
+		if got, err := ParseLevel(input); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", input, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("ParseLevel(loud) error = nil, want error")
+	}
+}
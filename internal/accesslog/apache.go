@@ -0,0 +1,80 @@
+package accesslog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+)
+
+const apacheTime = "02/Jan/2006:15:04:05 -0700"
+
+// apacheHandler renders request records in the Apache common or combined
+// log format. Records without request attributes are written with "-" in
+// their place.
+type apacheHandler struct {
+	w        io.Writer
+	level    slog.Leveler
+	combined bool
+	attrs    []slog.Attr
+
+	// mu is shared with clones so lines never interleave
+	mu *sync.Mutex
+}
+
+func (h *apacheHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *apacheHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return &clone
+}
+
+func (h *apacheHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+func (h *apacheHandler) Handle(_ context.Context, r slog.Record) error {
+	fields := make(map[string]string)
+	add := func(a slog.Attr) bool {
+		fields[a.Key] = a.Value.String()
+		return true
+	}
+	for _, a := range h.attrs {
+		add(a)
+	}
+	r.Attrs(add)
+
+	field := func(key string) string {
+		if v := fields[key]; v != "" {
+			return v
+		}
+		return "-"
+	}
+
+	size := field("bytes")
+	if size == "0" {
+		size = "-"
+	}
+
+	line := fmt.Sprintf("%s - %s [%s] %s %s %s",
+		field("remote_ip"),
+		field("user"),
+		r.Time.Format(apacheTime),
+		strconv.Quote(fields["method"]+" "+fields["uri"]+" "+fields["proto"]),
+		field("status"),
+		size,
+	)
+	if h.combined {
+		line += fmt.Sprintf(" %s %s", strconv.Quote(field("referer")), strconv.Quote(field("user_agent")))
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, line+"\n")
+	return err
+}
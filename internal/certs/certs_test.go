@@ -0,0 +1,78 @@
+package certs
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func TestNewAuthority(t *testing.T) {
+	ca, err := NewAuthority()
+	if err != nil {
+		t.Fatalf("NewAuthority() error = %v", err)
+	}
+
+	if !ca.Cert.IsCA {
+		t.Error("NewAuthority() certificate is not a CA")
+	}
+
+	block, _ := pem.Decode(ca.CertPEM())
+	if block == nil || block.Type != "CERTIFICATE" {
+		t.Fatalf("CertPEM() = %v, want a CERTIFICATE block", block)
+	}
+	if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+		t.Errorf("CertPEM() does not parse: %v", err)
+	}
+}
+
+func TestAuthority_Issue(t *testing.T) {
+	ca, err := NewAuthority()
+	if err != nil {
+		t.Fatalf("NewAuthority() error = %v", err)
+	}
+
+	cert, err := ca.Issue([]string{"localhost", "echobox.test", "127.0.0.1", "::1"})
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	if len(cert.Certificate) != 2 {
+		t.Errorf("Issue() chain length = %d, want 2", len(cert.Certificate))
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+
+	for _, host := range []string{"localhost", "echobox.test", "127.0.0.1", "::1"} {
+		if _, err := cert.Leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: pool}); err != nil {
+			t.Errorf("Issue() leaf does not verify for %s: %v", host, err)
+		}
+	}
+
+	if _, err := cert.Leaf.Verify(x509.VerifyOptions{DNSName: "other.test", Roots: pool}); err == nil {
+		t.Error("Issue() leaf verifies for a host outside its SANs")
+	}
+}
+
+func TestAuthority_IssueClient(t *testing.T) {
+	ca, err := NewAuthority()
+	if err != nil {
+		t.Fatalf("NewAuthority() error = %v", err)
+	}
+
+	cert, err := ca.IssueClient("client-a")
+	if err != nil {
+		t.Fatalf("IssueClient() error = %v", err)
+	}
+
+	if cert.Leaf.Subject.CommonName != "client-a" {
+		t.Errorf("IssueClient() CN = %v, want client-a", cert.Leaf.Subject.CommonName)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	_, err = cert.Leaf.Verify(x509.VerifyOptions{Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	if err != nil {
+		t.Errorf("IssueClient() leaf does not verify for client auth: %v", err)
+	}
+}
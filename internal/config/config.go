@@ -1,42 +1,388 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
-	DefaultPort         = "5867"
-	DefaultReadTimeout  = 30
-	DefaultWriteTimeout = 30
+	DefaultPort        = "5867"
+	DefaultRawEchoMode = "raw"
+	DefaultTLSHosts    = "localhost,127.0.0.1,::1"
+	DefaultClientAuth  = "none"
+	DefaultServiceName = "echobox"
+
+	DefaultAccessLogFormat = "text"
+	DefaultAccessLogLevel  = "info"
+	DefaultAccessLogRedact = "Authorization,Proxy-Authorization,Cookie,Set-Cookie,X-Api-Key"
+
+	DefaultCORSMethods       = "GET,POST,PUT,PATCH,DELETE,HEAD,OPTIONS"
+	DefaultCORSExposeHeaders = "X-Request-Id"
+
+	DefaultReadTimeout     = 30 * time.Second
+	DefaultWriteTimeout    = 30 * time.Second
+	DefaultShutdownTimeout = 5 * time.Second
+	DefaultMaxHeaderBytes  = 1 << 20
+	DefaultMaxBodyBytes    = 10 << 20
+
+	DefaultUnixSocketMode os.FileMode = 0o660
 )
 
+// Server holds the HTTP server settings. When Listen is set, the server
+// listens on those addresses instead of Port. The json tags name the keys of
+// configuration files and the field paths used in validation errors.
+//
+// ReadHeaderTimeout and IdleTimeout fall back to ReadTimeout when zero, as in
+// net/http. On shutdown the server first reports not ready for ShutdownDelay
+// while still serving, then ShutdownTimeout bounds how long in-flight
+// requests may take to finish.
 type Server struct {
-	Port         string
-	ReadTimeout  int
-	WriteTimeout int
+	Port              string        `json:"port"`
+	Listen            []string      `json:"listen"`
+	UnixSocketMode    os.FileMode   `json:"unix_socket_mode"`
+	ReadTimeout       time.Duration `json:"read_timeout"`
+	ReadHeaderTimeout time.Duration `json:"read_header_timeout"`
+	WriteTimeout      time.Duration `json:"write_timeout"`
+	IdleTimeout       time.Duration `json:"idle_timeout"`
+	ShutdownDelay     time.Duration `json:"shutdown_delay"`
+	ShutdownTimeout   time.Duration `json:"shutdown_timeout"`
+	MaxHeaderBytes    int           `json:"max_header_bytes"`
+	KeepAlive         bool          `json:"keep_alive"`
+	MaxBodyBytes      int           `json:"max_body_bytes"`
+	BodyEchoLimit     int           `json:"body_echo_limit"`
+	H2C               bool          `json:"h2c"`
+	Metrics           bool          `json:"metrics"`
+	RawCapture        bool          `json:"raw_capture"`
+	RawEcho           RawEcho       `json:"raw_echo"`
+	TLS               TLS           `json:"tls"`
+	Proxy             Proxy         `json:"proxy"`
+	AccessLog         AccessLog     `json:"access_log"`
+	Admin             Admin         `json:"admin"`
+	Tracing           Tracing       `json:"tracing"`
+	CORS              CORS          `json:"cors"`
+	Upload            Upload        `json:"upload"`
+	Routes            []Route       `json:"routes"`
+
+	// loadErrs collects values that could not be parsed while loading, so
+	// Validate can report them together with its own checks.
+	loadErrs []error
+}
+
+// RawEcho configures the optional TCP and UDP echo listeners. A listener is
+// only started when its port is set.
+type RawEcho struct {
+	TCPPort string `json:"tcp_port"`
+	UDPPort string `json:"udp_port"`
+	Mode    string `json:"mode"`
+	HexDump bool   `json:"hexdump"`
+	DelayMs int    `json:"delay_ms"`
+	Limit   int    `json:"limit"`
+}
+
+// TLS configures the HTTPS listener, started only when Port is set. Without
+// CertFile and KeyFile a CA and a leaf certificate for Hosts are generated in
+// memory at startup. ClientAuth is one of none, request, require or verify;
+// client certificates are checked against ClientCA, which verify requires.
+type TLS struct {
+	Port       string   `json:"port"`
+	CertFile   string   `json:"cert_file"`
+	KeyFile    string   `json:"key_file"`
+	Hosts      []string `json:"hosts"`
+	ClientAuth string   `json:"client_auth"`
+	ClientCA   string   `json:"client_ca"`
+}
+
+// Proxy enables PROXY protocol decoding on the HTTP and HTTPS listeners.
+// Headers are only honoured from Trusted networks, which must be set when
+// Enabled.
+type Proxy struct {
+	Enabled bool     `json:"enabled"`
+	Trusted []string `json:"trusted"`
+}
+
+// AccessLog configures the per-request log written to stdout. Format is one
+// of text, json, combined or common. Body is the number of request body bytes
+// to include; Redact lists headers whose values are masked.
+type AccessLog struct {
+	Enabled bool     `json:"enabled"`
+	Format  string   `json:"format"`
+	Level   string   `json:"level"`
+	Headers bool     `json:"headers"`
+	Body    int      `json:"body"`
+	Redact  []string `json:"redact"`
+}
+
+// Admin serves the admin API under /_admin/ when Enabled. When Token is set,
+// requests must send it as a bearer token; without one, anyone who can reach
+// the port can fail /_health.
+type Admin struct {
+	Enabled bool   `json:"enabled"`
+	Token   string `json:"token"`
+}
+
+// Upload bounds the multipart uploads /upload accepts, 0 meaning
+// unlimited. The whole body is also bounded by MaxBodyBytes.
+type Upload struct {
+	MaxPartBytes int `json:"max_part_bytes"`
+	MaxParts     int `json:"max_parts"`
+}
+
+// Route serves a fixed response at Path, a net/http pattern such as
+// /users/{id}, in place of any built-in endpoint there. Empty Methods allow
+// the standard methods and a Status of 0 means 200. Routes are only read from
+// the configuration file.
+type Route struct {
+	Path    string            `json:"path"`
+	Methods []string          `json:"methods"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
 }
 
+// Tracing exports a server span for every request over OTLP/HTTP JSON to
+// Endpoint, such as http://localhost:4318/v1/traces, when it is set.
+type Tracing struct {
+	Endpoint    string `json:"endpoint"`
+	ServiceName string `json:"service_name"`
+}
+
+// CORS answers cross-origin requests and preflights when Enabled. Origins
+// may be "*", exact origins, wildcards such as https://*.example.com or
+// regular expressions between slashes. Empty Methods or Headers allow any.
+// Reflect allows whatever the browser asks for, with credentials.
+type CORS struct {
+	Enabled       bool          `json:"enabled"`
+	Reflect       bool          `json:"reflect"`
+	Origins       []string      `json:"origins"`
+	Methods       []string      `json:"methods"`
+	Headers       []string      `json:"headers"`
+	ExposeHeaders []string      `json:"expose_headers"`
+	Credentials   bool          `json:"credentials"`
+	MaxAge        time.Duration `json:"max_age"`
+}
+
+func Default() *Server {
+	return &Server{
+		Port:            DefaultPort,
+		UnixSocketMode:  DefaultUnixSocketMode,
+		ReadTimeout:     DefaultReadTimeout,
+		WriteTimeout:    DefaultWriteTimeout,
+		ShutdownTimeout: DefaultShutdownTimeout,
+		MaxHeaderBytes:  DefaultMaxHeaderBytes,
+		MaxBodyBytes:    DefaultMaxBodyBytes,
+		KeepAlive:       true,
+		H2C:             true,
+		Metrics:         true,
+		RawEcho:         RawEcho{Mode: DefaultRawEchoMode},
+		TLS: TLS{
+			Hosts:      splitList(DefaultTLSHosts),
+			ClientAuth: DefaultClientAuth,
+		},
+		AccessLog: AccessLog{
+			Enabled: true,
+			Format:  DefaultAccessLogFormat,
+			Level:   DefaultAccessLogLevel,
+			Redact:  splitList(DefaultAccessLogRedact),
+		},
+		Tracing: Tracing{ServiceName: DefaultServiceName},
+		CORS: CORS{
+			Origins:       []string{"*"},
+			Methods:       splitList(DefaultCORSMethods),
+			ExposeHeaders: splitList(DefaultCORSExposeHeaders),
+		},
+	}
+}
+
+// Load reads the configuration file named by ECHOBOX_CONFIG, if any, and
+// then the environment.
 func Load() *Server {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = DefaultPort
+	return LoadFrom(os.Getenv("ECHOBOX_CONFIG"))
+}
+
+// LoadFrom starts from the defaults, applies the configuration file at path
+// when it is not empty, then environment variables. Unparseable values leave
+// the previous setting in place and are reported by Validate.
+func LoadFrom(path string) *Server {
+	s := Default()
+	if path != "" {
+		if err := s.LoadFile(path); err != nil {
+			s.loadErrs = append(s.loadErrs, err)
+		}
 	}
+	s.loadEnv()
+	return s
+}
 
-	readTimeout := getEnvInt("READ_TIMEOUT", DefaultReadTimeout)
-	writeTimeout := getEnvInt("WRITE_TIMEOUT", DefaultWriteTimeout)
+func (s *Server) loadEnv() {
+	env := &envLoader{}
 
-	return &Server{
-		Port:         port,
-		ReadTimeout:  readTimeout,
-		WriteTimeout: writeTimeout,
+	env.string("PORT", &s.Port)
+	env.list("LISTEN", &s.Listen)
+	env.fileMode("UNIX_SOCKET_MODE", &s.UnixSocketMode)
+	env.duration("READ_TIMEOUT", &s.ReadTimeout)
+	env.duration("READ_HEADER_TIMEOUT", &s.ReadHeaderTimeout)
+	env.duration("WRITE_TIMEOUT", &s.WriteTimeout)
+	env.duration("IDLE_TIMEOUT", &s.IdleTimeout)
+	env.duration("SHUTDOWN_DELAY", &s.ShutdownDelay)
+	env.duration("SHUTDOWN_TIMEOUT", &s.ShutdownTimeout)
+	env.int("MAX_HEADER_BYTES", &s.MaxHeaderBytes)
+	env.bool("KEEP_ALIVE", &s.KeepAlive)
+	env.int("MAX_BODY_BYTES", &s.MaxBodyBytes)
+	env.int("BODY_ECHO_LIMIT", &s.BodyEchoLimit)
+	env.bool("H2C", &s.H2C)
+	env.bool("METRICS", &s.Metrics)
+	env.bool("RAW_CAPTURE", &s.RawCapture)
+
+	env.string("TCP_ECHO_PORT", &s.RawEcho.TCPPort)
+	env.string("UDP_ECHO_PORT", &s.RawEcho.UDPPort)
+	env.string("RAW_ECHO_MODE", &s.RawEcho.Mode)
+	env.bool("RAW_ECHO_HEXDUMP", &s.RawEcho.HexDump)
+	env.int("RAW_ECHO_DELAY_MS", &s.RawEcho.DelayMs)
+	env.int("RAW_ECHO_LIMIT", &s.RawEcho.Limit)
+
+	env.string("TLS_PORT", &s.TLS.Port)
+	env.string("TLS_CERT_FILE", &s.TLS.CertFile)
+	env.string("TLS_KEY_FILE", &s.TLS.KeyFile)
+	env.list("TLS_HOSTS", &s.TLS.Hosts)
+	env.string("TLS_CLIENT_AUTH", &s.TLS.ClientAuth)
+	env.string("TLS_CLIENT_CA", &s.TLS.ClientCA)
+
+	env.bool("PROXY_PROTOCOL", &s.Proxy.Enabled)
+	env.list("PROXY_TRUSTED", &s.Proxy.Trusted)
+
+	env.bool("ACCESS_LOG", &s.AccessLog.Enabled)
+	env.string("ACCESS_LOG_FORMAT", &s.AccessLog.Format)
+	env.string("ACCESS_LOG_LEVEL", &s.AccessLog.Level)
+	env.bool("ACCESS_LOG_HEADERS", &s.AccessLog.Headers)
+	env.int("ACCESS_LOG_BODY", &s.AccessLog.Body)
+	env.list("ACCESS_LOG_REDACT", &s.AccessLog.Redact)
+
+	env.bool("ADMIN", &s.Admin.Enabled)
+	env.string("ADMIN_TOKEN", &s.Admin.Token)
+
+	env.string("OTLP_ENDPOINT", &s.Tracing.Endpoint)
+	env.string("OTLP_SERVICE_NAME", &s.Tracing.ServiceName)
+
+	env.bool("CORS", &s.CORS.Enabled)
+	env.bool("CORS_REFLECT", &s.CORS.Reflect)
+	env.list("CORS_ORIGINS", &s.CORS.Origins)
+	env.list("CORS_METHODS", &s.CORS.Methods)
+	env.list("CORS_HEADERS", &s.CORS.Headers)
+	env.list("CORS_EXPOSE_HEADERS", &s.CORS.ExposeHeaders)
+	env.bool("CORS_CREDENTIALS", &s.CORS.Credentials)
+	env.duration("CORS_MAX_AGE", &s.CORS.MaxAge)
+
+	env.int("UPLOAD_MAX_PART_BYTES", &s.Upload.MaxPartBytes)
+	env.int("UPLOAD_MAX_PARTS", &s.Upload.MaxParts)
+
+	s.loadErrs = append(s.loadErrs, env.errs...)
+}
+
+// envLoader overrides settings with the environment variables that are set,
+// recording rather than ignoring values that do not parse.
+type envLoader struct {
+	errs []error
+}
+
+func (e *envLoader) lookup(key string) (string, bool) {
+	val := os.Getenv(key)
+	return val, val != ""
+}
+
+func (e *envLoader) fail(key, kind, val string) {
+	e.errs = append(e.errs, fmt.Errorf("%s: invalid %s %q", key, kind, val))
+}
+
+func (e *envLoader) string(key string, dst *string) {
+	if val, ok := e.lookup(key); ok {
+		*dst = val
+	}
+}
+
+func (e *envLoader) int(key string, dst *int) {
+	val, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		e.fail(key, "integer", val)
+		return
+	}
+	*dst = n
+}
+
+func (e *envLoader) bool(key string, dst *bool) {
+	val, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		e.fail(key, "boolean", val)
+		return
+	}
+	*dst = b
+}
+
+func (e *envLoader) duration(key string, dst *time.Duration) {
+	val, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	d, err := ParseDuration(val)
+	if err != nil {
+		e.fail(key, "duration", val)
+		return
+	}
+	*dst = d
+}
+
+func (e *envLoader) list(key string, dst *[]string) {
+	if val, ok := e.lookup(key); ok {
+		*dst = splitList(val)
+	}
+}
+
+func (e *envLoader) fileMode(key string, dst *os.FileMode) {
+	val, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	mode, err := parseFileMode(val)
+	if err != nil {
+		e.fail(key, "octal mode", val)
+		return
+	}
+	*dst = mode
+}
+
+// ParseDuration accepts Go duration strings such as "500ms" or "2m", and
+// bare integers as seconds for compatibility with earlier settings.
+func ParseDuration(s string) (time.Duration, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func parseFileMode(s string) (os.FileMode, error) {
+	val, err := strconv.ParseUint(strings.TrimPrefix(s, "0o"), 8, 32)
+	if err != nil || val > 0o777 {
+		return 0, fmt.Errorf("invalid octal mode %q", s)
 	}
+	return os.FileMode(val), nil
 }
 
-func getEnvInt(key string, defaultVal int) int {
-	valStr := os.Getenv(key)
-	if val, err := strconv.Atoi(valStr); err == nil {
-		return val
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
 	}
-	return defaultVal
+	return list
 }
@@ -2,7 +2,10 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -12,8 +15,8 @@ func TestLoad(t *testing.T) {
 		envRead  string
 		envWrite string
 		wantPort string
-		wantRead int
-		wantWrite int
+		wantRead time.Duration
+		wantWrite time.Duration
 	}{
 		{
 			name:      "defaults when no env vars",
@@ -27,8 +30,8 @@ func TestLoad(t *testing.T) {
 			envRead:  "60",
 			envWrite: "90",
 			wantPort: "8080",
-			wantRead:  60,
-			wantWrite: 90,
+			wantRead:  60 * time.Second,
+			wantWrite: 90 * time.Second,
 		},
 		{
 			name:     "invalid env vars use defaults",
@@ -73,3 +76,385 @@ func TestLoad(t *testing.T) {
 		})
 	}
 }
+
+func TestLoad_RawEcho(t *testing.T) {
+	keys := []string{"TCP_ECHO_PORT", "UDP_ECHO_PORT", "RAW_ECHO_MODE", "RAW_ECHO_HEXDUMP", "RAW_ECHO_DELAY_MS", "RAW_ECHO_LIMIT"}
+	for _, k := range keys {
+		os.Unsetenv(k)
+	}
+	defer func() {
+		for _, k := range keys {
+			os.Unsetenv(k)
+		}
+	}()
+
+	got := Load().RawEcho
+	if got.TCPPort != "" || got.UDPPort != "" {
+		t.Errorf("Load().RawEcho ports = %q/%q, want disabled by default", got.TCPPort, got.UDPPort)
+	}
+	if got.Mode != DefaultRawEchoMode {
+		t.Errorf("Load().RawEcho.Mode = %v, want %v", got.Mode, DefaultRawEchoMode)
+	}
+
+	os.Setenv("TCP_ECHO_PORT", "7000")
+	os.Setenv("UDP_ECHO_PORT", "7001")
+	os.Setenv("RAW_ECHO_MODE", "line")
+	os.Setenv("RAW_ECHO_HEXDUMP", "true")
+	os.Setenv("RAW_ECHO_DELAY_MS", "250")
+	os.Setenv("RAW_ECHO_LIMIT", "1024")
+
+	got = Load().RawEcho
+	want := RawEcho{TCPPort: "7000", UDPPort: "7001", Mode: "line", HexDump: true, DelayMs: 250, Limit: 1024}
+	if got != want {
+		t.Errorf("Load().RawEcho = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoad_TLS(t *testing.T) {
+	keys := []string{"TLS_PORT", "TLS_CERT_FILE", "TLS_KEY_FILE", "TLS_HOSTS", "TLS_CLIENT_AUTH", "TLS_CLIENT_CA"}
+	for _, k := range keys {
+		os.Unsetenv(k)
+	}
+	defer func() {
+		for _, k := range keys {
+			os.Unsetenv(k)
+		}
+	}()
+
+	got := Load().TLS
+	if got.Port != "" {
+		t.Errorf("Load().TLS.Port = %q, want disabled by default", got.Port)
+	}
+	if len(got.Hosts) != 3 || got.Hosts[0] != "localhost" {
+		t.Errorf("Load().TLS.Hosts = %v, want default hosts", got.Hosts)
+	}
+	if got.ClientAuth != DefaultClientAuth {
+		t.Errorf("Load().TLS.ClientAuth = %q, want %q", got.ClientAuth, DefaultClientAuth)
+	}
+
+	os.Setenv("TLS_PORT", "8443")
+	os.Setenv("TLS_HOSTS", "echobox.test, 10.0.0.1,")
+	os.Setenv("TLS_CLIENT_AUTH", "require")
+	os.Setenv("TLS_CLIENT_CA", "/etc/echobox/clients.pem")
+
+	got = Load().TLS
+	if got.Port != "8443" {
+		t.Errorf("Load().TLS.Port = %q, want 8443", got.Port)
+	}
+	if len(got.Hosts) != 2 || got.Hosts[0] != "echobox.test" || got.Hosts[1] != "10.0.0.1" {
+		t.Errorf("Load().TLS.Hosts = %v, want [echobox.test 10.0.0.1]", got.Hosts)
+	}
+	if got.ClientAuth != "require" || got.ClientCA != "/etc/echobox/clients.pem" {
+		t.Errorf("Load().TLS client auth = %q/%q, want require with CA file", got.ClientAuth, got.ClientCA)
+	}
+}
+
+func TestLoad_H2C(t *testing.T) {
+	os.Unsetenv("H2C")
+	defer os.Unsetenv("H2C")
+
+	if !Load().H2C {
+		t.Error("Load().H2C = false, want enabled by default")
+	}
+
+	os.Setenv("H2C", "false")
+	if Load().H2C {
+		t.Error("Load().H2C = true, want false when H2C=false")
+	}
+}
+
+func TestLoad_Listen(t *testing.T) {
+	os.Unsetenv("LISTEN")
+	os.Unsetenv("UNIX_SOCKET_MODE")
+	defer os.Unsetenv("LISTEN")
+	defer os.Unsetenv("UNIX_SOCKET_MODE")
+
+	got := Load()
+	if len(got.Listen) != 0 || got.UnixSocketMode != DefaultUnixSocketMode {
+		t.Errorf("Load() listen = %v mode = %v, want none and %v", got.Listen, got.UnixSocketMode, DefaultUnixSocketMode)
+	}
+
+	os.Setenv("LISTEN", ":8080, unix:///tmp/echobox.sock")
+	os.Setenv("UNIX_SOCKET_MODE", "0600")
+
+	got = Load()
+	if len(got.Listen) != 2 || got.Listen[1] != "unix:///tmp/echobox.sock" {
+		t.Errorf("Load().Listen = %v, want two addresses", got.Listen)
+	}
+	if got.UnixSocketMode != 0o600 {
+		t.Errorf("Load().UnixSocketMode = %v, want 0600", got.UnixSocketMode)
+	}
+}
+
+func TestLoad_Proxy(t *testing.T) {
+	os.Unsetenv("PROXY_PROTOCOL")
+	os.Unsetenv("PROXY_TRUSTED")
+	defer os.Unsetenv("PROXY_PROTOCOL")
+	defer os.Unsetenv("PROXY_TRUSTED")
+
+	if got := Load().Proxy; got.Enabled || len(got.Trusted) != 0 {
+		t.Errorf("Load().Proxy = %+v, want disabled by default", got)
+	}
+
+	os.Setenv("PROXY_PROTOCOL", "true")
+	os.Setenv("PROXY_TRUSTED", "10.0.0.0/8,192.168.1.10")
+
+	got := Load().Proxy
+	if !got.Enabled || len(got.Trusted) != 2 || got.Trusted[0] != "10.0.0.0/8" {
+		t.Errorf("Load().Proxy = %+v, want enabled with two trusted networks", got)
+	}
+}
+
+func TestLoad_InvalidEnv(t *testing.T) {
+	os.Setenv("READ_TIMEOUT", "3o")
+	os.Setenv("H2C", "maybe")
+	defer os.Unsetenv("READ_TIMEOUT")
+	defer os.Unsetenv("H2C")
+
+	cfg := Load()
+	if cfg.ReadTimeout != DefaultReadTimeout {
+		t.Errorf("Load().ReadTimeout = %v, want default kept", cfg.ReadTimeout)
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() error = nil, want invalid READ_TIMEOUT reported")
+	}
+	for _, want := range []string{`READ_TIMEOUT: invalid duration "3o"`, `H2C: invalid boolean "maybe"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error = %v, want it to contain %s", err, want)
+		}
+	}
+}
+
+func TestLoad_ConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "echobox.yaml")
+	os.WriteFile(path, []byte("port: 7000\nread_timeout: 5\n"), 0o600)
+
+	os.Setenv("ECHOBOX_CONFIG", path)
+	os.Setenv("READ_TIMEOUT", "9")
+	defer os.Unsetenv("ECHOBOX_CONFIG")
+	defer os.Unsetenv("READ_TIMEOUT")
+
+	cfg := Load()
+	if cfg.Port != "7000" {
+		t.Errorf("Load().Port = %v, want 7000 from the file", cfg.Port)
+	}
+	if cfg.ReadTimeout != 9*time.Second {
+		t.Errorf("Load().ReadTimeout = %v, want the environment to override the file", cfg.ReadTimeout)
+	}
+
+	os.Setenv("ECHOBOX_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+	if err := Load().Validate(); err == nil {
+		t.Error("Validate() error = nil, want missing config file reported")
+	}
+}
+
+func TestLoad_Tuning(t *testing.T) {
+	keys := []string{"READ_TIMEOUT", "READ_HEADER_TIMEOUT", "IDLE_TIMEOUT", "SHUTDOWN_TIMEOUT", "MAX_HEADER_BYTES", "KEEP_ALIVE", "MAX_BODY_BYTES", "BODY_ECHO_LIMIT"}
+	for _, k := range keys {
+		os.Unsetenv(k)
+	}
+	defer func() {
+		for _, k := range keys {
+			os.Unsetenv(k)
+		}
+	}()
+
+	got := Load()
+	if got.ShutdownTimeout != DefaultShutdownTimeout || got.MaxHeaderBytes != DefaultMaxHeaderBytes || !got.KeepAlive ||
+		got.MaxBodyBytes != DefaultMaxBodyBytes || got.BodyEchoLimit != 0 {
+		t.Errorf("Load() = %+v, want default tuning", got)
+	}
+
+	os.Setenv("READ_TIMEOUT", "1m30s")
+	os.Setenv("READ_HEADER_TIMEOUT", "500ms")
+	os.Setenv("IDLE_TIMEOUT", "2m")
+	os.Setenv("SHUTDOWN_TIMEOUT", "10")
+	os.Setenv("MAX_HEADER_BYTES", "8192")
+	os.Setenv("KEEP_ALIVE", "false")
+	os.Setenv("MAX_BODY_BYTES", "0")
+	os.Setenv("BODY_ECHO_LIMIT", "4096")
+
+	got = Load()
+	if got.ReadTimeout != 90*time.Second || got.ReadHeaderTimeout != 500*time.Millisecond || got.IdleTimeout != 2*time.Minute {
+		t.Errorf("Load() timeouts = %v/%v/%v, want 1m30s/500ms/2m", got.ReadTimeout, got.ReadHeaderTimeout, got.IdleTimeout)
+	}
+	if got.ShutdownTimeout != 10*time.Second {
+		t.Errorf("Load().ShutdownTimeout = %v, want bare number read as seconds", got.ShutdownTimeout)
+	}
+	if got.MaxHeaderBytes != 8192 || got.KeepAlive {
+		t.Errorf("Load() = %d bytes keep-alive %v, want 8192 and false", got.MaxHeaderBytes, got.KeepAlive)
+	}
+	if got.MaxBodyBytes != 0 || got.BodyEchoLimit != 4096 {
+		t.Errorf("Load() body limits = %d/%d, want 0/4096", got.MaxBodyBytes, got.BodyEchoLimit)
+	}
+
+	os.Setenv("IDLE_TIMEOUT", "2 minutes")
+	if err := Load().Validate(); err == nil || !strings.Contains(err.Error(), "IDLE_TIMEOUT") {
+		t.Errorf("Validate() error = %v, want invalid IDLE_TIMEOUT", err)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{input: "30", want: 30 * time.Second},
+		{input: "0", want: 0},
+		{input: "500ms", want: 500 * time.Millisecond},
+		{input: "2m", want: 2 * time.Minute},
+		{input: "1h30m", want: 90 * time.Minute},
+		{input: "3o", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDuration(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestLoad_Metrics(t *testing.T) {
+	os.Unsetenv("METRICS")
+	defer os.Unsetenv("METRICS")
+
+	if !Load().Metrics {
+		t.Error("Load().Metrics = false, want enabled by default")
+	}
+
+	os.Setenv("METRICS", "false")
+	if Load().Metrics {
+		t.Error("Load().Metrics = true, want false when METRICS=false")
+	}
+}
+
+func TestLoad_RawCapture(t *testing.T) {
+	os.Unsetenv("RAW_CAPTURE")
+	defer os.Unsetenv("RAW_CAPTURE")
+
+	if Load().RawCapture {
+		t.Error("Load().RawCapture = true, want disabled by default")
+	}
+
+	os.Setenv("RAW_CAPTURE", "true")
+	if !Load().RawCapture {
+		t.Error("Load().RawCapture = false, want true when RAW_CAPTURE=true")
+	}
+}
+
+func TestLoad_Admin(t *testing.T) {
+	os.Unsetenv("ADMIN")
+	os.Unsetenv("ADMIN_TOKEN")
+	defer os.Unsetenv("ADMIN")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	if got := Load().Admin; got.Enabled || got.Token != "" {
+		t.Errorf("Load().Admin = %+v, want disabled without a token", got)
+	}
+
+	os.Setenv("ADMIN", "true")
+	os.Setenv("ADMIN_TOKEN", "secret")
+	if got := Load().Admin; !got.Enabled || got.Token != "secret" {
+		t.Errorf("Load().Admin = %+v, want values from the environment", got)
+	}
+}
+
+func TestLoad_Upload(t *testing.T) {
+	os.Setenv("UPLOAD_MAX_PART_BYTES", "1024")
+	os.Setenv("UPLOAD_MAX_PARTS", "3")
+	defer os.Unsetenv("UPLOAD_MAX_PART_BYTES")
+	defer os.Unsetenv("UPLOAD_MAX_PARTS")
+
+	if got := Load().Upload; got.MaxPartBytes != 1024 || got.MaxParts != 3 {
+		t.Errorf("Load().Upload = %+v, want values from the environment", got)
+	}
+}
+
+func TestLoad_Tracing(t *testing.T) {
+	os.Unsetenv("OTLP_ENDPOINT")
+	os.Unsetenv("OTLP_SERVICE_NAME")
+	defer os.Unsetenv("OTLP_ENDPOINT")
+	defer os.Unsetenv("OTLP_SERVICE_NAME")
+
+	if got := Load().Tracing; got.Endpoint != "" || got.ServiceName != DefaultServiceName {
+		t.Errorf("Load().Tracing = %+v, want disabled with the default service name", got)
+	}
+
+	os.Setenv("OTLP_ENDPOINT", "http://collector:4318/v1/traces")
+	os.Setenv("OTLP_SERVICE_NAME", "stub-payments")
+	if got := Load().Tracing; got.Endpoint != "http://collector:4318/v1/traces" || got.ServiceName != "stub-payments" {
+		t.Errorf("Load().Tracing = %+v, want values from the environment", got)
+	}
+}
+
+func TestLoad_CORS(t *testing.T) {
+	keys := []string{"CORS", "CORS_REFLECT", "CORS_ORIGINS", "CORS_METHODS", "CORS_HEADERS", "CORS_EXPOSE_HEADERS", "CORS_CREDENTIALS", "CORS_MAX_AGE"}
+	for _, k := range keys {
+		os.Unsetenv(k)
+	}
+	defer func() {
+		for _, k := range keys {
+			os.Unsetenv(k)
+		}
+	}()
+
+	got := Load().CORS
+	if got.Enabled || len(got.Origins) != 1 || got.Origins[0] != "*" || len(got.Methods) != 7 || len(got.ExposeHeaders) != 1 {
+		t.Errorf("Load().CORS = %+v, want disabled, any origin and default methods", got)
+	}
+
+	os.Setenv("CORS", "true")
+	os.Setenv("CORS_REFLECT", "true")
+	os.Setenv("CORS_ORIGINS", "https://a.test,https://*.b.test")
+	os.Setenv("CORS_METHODS", "GET")
+	os.Setenv("CORS_HEADERS", "X-Token")
+	os.Setenv("CORS_EXPOSE_HEADERS", "")
+	os.Setenv("CORS_CREDENTIALS", "true")
+	os.Setenv("CORS_MAX_AGE", "10m")
+
+	got = Load().CORS
+	if !got.Enabled || !got.Reflect || len(got.Origins) != 2 || len(got.Methods) != 1 || len(got.Headers) != 1 || !got.Credentials || got.MaxAge != 10*time.Minute {
+		t.Errorf("Load().CORS = %+v, want values from the environment", got)
+	}
+}
+
+func TestLoad_AccessLog(t *testing.T) {
+	keys := []string{"ACCESS_LOG", "ACCESS_LOG_FORMAT", "ACCESS_LOG_LEVEL", "ACCESS_LOG_HEADERS", "ACCESS_LOG_BODY", "ACCESS_LOG_REDACT"}
+	for _, k := range keys {
+		os.Unsetenv(k)
+	}
+	defer func() {
+		for _, k := range keys {
+			os.Unsetenv(k)
+		}
+	}()
+
+	got := Load().AccessLog
+	if !got.Enabled || got.Format != DefaultAccessLogFormat || got.Level != DefaultAccessLogLevel || len(got.Redact) != 5 {
+		t.Errorf("Load().AccessLog = %+v, want enabled text logs at info with default redaction", got)
+	}
+
+	os.Setenv("ACCESS_LOG", "false")
+	os.Setenv("ACCESS_LOG_FORMAT", "combined")
+	os.Setenv("ACCESS_LOG_LEVEL", "warn")
+	os.Setenv("ACCESS_LOG_HEADERS", "true")
+	os.Setenv("ACCESS_LOG_BODY", "256")
+	os.Setenv("ACCESS_LOG_REDACT", "X-Token")
+
+	got = Load().AccessLog
+	if got.Enabled || got.Format != "combined" || got.Level != "warn" || !got.Headers || got.Body != 256 {
+		t.Errorf("Load().AccessLog = %+v, want values from the environment", got)
+	}
+	if len(got.Redact) != 1 || got.Redact[0] != "X-Token" {
+		t.Errorf("Load().AccessLog.Redact = %v, want [X-Token]", got.Redact)
+	}
+}
@@ -0,0 +1,324 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFile applies the YAML, JSON or TOML file at path to s, choosing the
+// format from the extension. Keys are the json tag names of the settings.
+// Unknown keys and values of the wrong type are all reported, each with its
+// field path, and leave the setting unchanged.
+func (s *Server) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var tree map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		tree, err = parseYAML(data)
+	case ".json":
+		tree, err = parseJSON(data)
+	case ".toml":
+		tree, err = parseTOML(data)
+	default:
+		return fmt.Errorf("%s: unsupported format, want .yaml, .yml, .json or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	var errs []error
+	decode("", tree, reflect.ValueOf(s).Elem(), &errs)
+	for i, err := range errs {
+		errs[i] = fmt.Errorf("%s: %w", path, err)
+	}
+	return errors.Join(errs...)
+}
+
+// parseYAML decodes data into the generic tree decode expects.
+func parseYAML(data []byte) (map[string]any, error) {
+	var tree any
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	return topLevel(tree)
+}
+
+// parseJSON decodes data into the generic tree decode expects.
+func parseJSON(data []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after the top-level object")
+	}
+	return topLevel(tree)
+}
+
+// parseTOML decodes data into the generic tree decode expects.
+func parseTOML(data []byte) (map[string]any, error) {
+	var tree map[string]any
+	if err := toml.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	return topLevel(tree)
+}
+
+// topLevel normalizes tree, which must be a mapping or empty.
+func topLevel(tree any) (map[string]any, error) {
+	if tree == nil {
+		return map[string]any{}, nil
+	}
+	m, ok := normalize(tree).(map[string]any)
+	if !ok {
+		return nil, errors.New("top level must be a mapping")
+	}
+	return m, nil
+}
+
+// normalize turns what the decoders produce into mappings with string keys,
+// []any lists and numbers as int64 or float64, so decode handles one shape.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case uint64:
+		// YAML integers over the int64 range
+		return float64(v)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for k, item := range v {
+			v[k] = normalize(item)
+		}
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = normalize(item)
+		}
+		return m
+	case []any:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+	case []map[string]any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = normalize(item)
+		}
+		return list
+	}
+	return v
+}
+
+var (
+	fileModeType = reflect.TypeOf(os.FileMode(0))
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// decode assigns node to v, appending an error naming path for every value
+// that does not fit the schema. Null values leave v unchanged.
+func decode(path string, node any, v reflect.Value, errs *[]error) {
+	if node == nil {
+		return
+	}
+	fail := func(want string) {
+		*errs = append(*errs, fmt.Errorf("%s: expected %s, got %s", displayPath(path), want, describe(node)))
+	}
+
+	switch {
+	case v.Type() == fileModeType:
+		str, ok := node.(string)
+		if !ok {
+			fail(`an octal string such as "0660"`)
+			return
+		}
+		mode, err := parseFileMode(str)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", displayPath(path), err))
+			return
+		}
+		v.Set(reflect.ValueOf(mode))
+
+	case v.Type() == durationType:
+		var (
+			d   time.Duration
+			err error
+		)
+		switch val := node.(type) {
+		case string:
+			d, err = ParseDuration(val)
+		case int64:
+			d = time.Duration(val) * time.Second
+		default:
+			fail(`a duration such as "30s" or a number of seconds`)
+			return
+		}
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: invalid duration %q", displayPath(path), node))
+			return
+		}
+		v.SetInt(int64(d))
+
+	case v.Kind() == reflect.Struct:
+		m, ok := node.(map[string]any)
+		if !ok {
+			fail("a mapping")
+			return
+		}
+		fields := structFields(v.Type())
+		for _, k := range sortedKeys(m) {
+			i, ok := fields[k]
+			if !ok {
+				*errs = append(*errs, fmt.Errorf("%s: unknown field", joinPath(path, k)))
+				continue
+			}
+			decode(joinPath(path, k), m[k], v.Field(i), errs)
+		}
+
+	case v.Kind() == reflect.String:
+		switch val := node.(type) {
+		case string:
+			v.SetString(val)
+		case int64:
+			// Ports are strings in Server but naturally written as numbers
+			v.SetString(strconv.FormatInt(val, 10))
+		default:
+			fail("a string")
+		}
+
+	case v.Kind() == reflect.Int:
+		n, ok := node.(int64)
+		if !ok {
+			fail("an integer")
+			return
+		}
+		v.SetInt(n)
+
+	case v.Kind() == reflect.Bool:
+		b, ok := node.(bool)
+		if !ok {
+			fail("a boolean")
+			return
+		}
+		v.SetBool(b)
+
+	case v.Kind() == reflect.Slice:
+		items, ok := node.([]any)
+		if !ok {
+			if v.Type().Elem().Kind() == reflect.String {
+				fail("a list of strings")
+			} else {
+				fail("a list")
+			}
+			return
+		}
+		list := reflect.MakeSlice(v.Type(), len(items), len(items))
+		before := len(*errs)
+		for i, item := range items {
+			decode(fmt.Sprintf("%s[%d]", path, i), item, list.Index(i), errs)
+		}
+		if len(*errs) == before {
+			v.Set(list)
+		}
+
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		m, ok := node.(map[string]any)
+		if !ok {
+			fail("a mapping")
+			return
+		}
+		out := reflect.MakeMapWithSize(v.Type(), len(m))
+		before := len(*errs)
+		for _, k := range sortedKeys(m) {
+			item := reflect.New(v.Type().Elem()).Elem()
+			decode(joinPath(path, k), m[k], item, errs)
+			out.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), item)
+		}
+		if len(*errs) == before {
+			v.Set(out)
+		}
+
+	default:
+		*errs = append(*errs, fmt.Errorf("%s: unsupported setting type %s", displayPath(path), v.Type()))
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// structFields maps the json tag names of t's exported fields to their index.
+func structFields(t reflect.Type) map[string]int {
+	fields := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		fields[name] = i
+	}
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "(top level)"
+	}
+	return path
+}
+
+func describe(node any) string {
+	switch v := node.(type) {
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case int64:
+		return fmt.Sprintf("integer %d", v)
+	case float64:
+		return fmt.Sprintf("number %v", v)
+	case bool:
+		return fmt.Sprintf("boolean %v", v)
+	case []any:
+		return "a list"
+	case map[string]any:
+		return "a mapping"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
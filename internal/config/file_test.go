@@ -0,0 +1,221 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestServer_LoadFile(t *testing.T) {
+	want := Default()
+	want.Port = "8080"
+	want.Listen = []string{":8080", "unix:///tmp/echobox.sock"}
+	want.UnixSocketMode = 0o600
+	want.ReadTimeout = 60 * time.Second
+	want.IdleTimeout = 2 * time.Minute
+	want.ShutdownTimeout = 500 * time.Millisecond
+	want.H2C = false
+	want.RawEcho = RawEcho{TCPPort: "7000", Mode: "line", HexDump: true, DelayMs: 250}
+	want.TLS.Port = "8443"
+	want.TLS.Hosts = []string{"echo.test"}
+	want.TLS.ClientAuth = "verify"
+	want.Proxy = Proxy{Enabled: true, Trusted: []string{"10.0.0.0/8"}}
+	want.Routes = []Route{
+		{Path: "/users/{id}", Methods: []string{"GET"}, Status: 201, Headers: map[string]string{"X-Stub": "yes"}, Body: "{\"id\": 1}\n"},
+		{Path: "/ping", Body: "pong"},
+	}
+
+	files := map[string]string{
+		"echobox.yaml": `
+# Listeners
+port: 8080
+listen:
+  - ":8080"
+  - unix:///tmp/echobox.sock
+unix_socket_mode: "0600"
+read_timeout: 60
+idle_timeout: 2m
+shutdown_timeout: 500ms
+h2c: false
+raw_echo:
+  tcp_port: "7000"
+  mode: line   # echo whole lines
+  hexdump: true
+  delay_ms: 250
+tls:
+  port: 8443
+  hosts: [echo.test]
+  client_auth: 'verify'
+proxy: {enabled: true, trusted: ["10.0.0.0/8"]}
+routes:
+  - path: /users/{id}
+    methods: [GET]
+    status: 201
+    headers: {X-Stub: "yes"}
+    body: |
+      {"id": 1}
+  - path: /ping
+    body: pong
+`,
+		"echobox.json": `{
+  "port": "8080",
+  "listen": [":8080", "unix:///tmp/echobox.sock"],
+  "unix_socket_mode": "0600",
+  "read_timeout": 60,
+  "idle_timeout": "2m",
+  "shutdown_timeout": "500ms",
+  "h2c": false,
+  "raw_echo": {"tcp_port": 7000, "mode": "line", "hexdump": true, "delay_ms": 250},
+  "tls": {"port": "8443", "hosts": ["echo.test"], "client_auth": "verify"},
+  "proxy": {"enabled": true, "trusted": ["10.0.0.0/8"]},
+  "routes": [
+    {"path": "/users/{id}", "methods": ["GET"], "status": 201, "headers": {"X-Stub": "yes"}, "body": "{\"id\": 1}\n"},
+    {"path": "/ping", "body": "pong"}
+  ]
+}`,
+		"echobox.toml": `
+port = 8080
+listen = [
+  ":8080",
+  "unix:///tmp/echobox.sock", # trailing comma allowed
+]
+unix_socket_mode = "0600"
+read_timeout = "1m"
+idle_timeout = "2m"
+shutdown_timeout = "500ms"
+h2c = false
+proxy = { enabled = true, trusted = ["10.0.0.0/8"] }
+
+[raw_echo]
+tcp_port = "7000"
+mode = 'line'
+hexdump = true
+delay_ms = 250
+
+[tls]
+port = "8443"
+hosts = ["echo.test"]
+client_auth = "verify"
+
+[[routes]]
+path = "/users/{id}"
+methods = ["GET"]
+status = 201
+headers.X-Stub = "yes"
+body = """
+{"id": 1}
+"""
+
+[[routes]]
+path = "/ping"
+body = "pong"
+`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			got := Default()
+			if err := got.LoadFile(writeConfig(t, name, content)); err != nil {
+				t.Fatalf("LoadFile() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("LoadFile() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestServer_LoadFile_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "schema errors are all reported with paths",
+			file:    "bad.yaml",
+			content: "read_timeout: 3o\nmax_header_bytes: 1KB\nprot: 80\ntls:\n  hosts: localhost\n  client_ca: [a]\nlisten: [1, {a: b}]\n",
+			want: []string{
+				`read_timeout: invalid duration "3o"`,
+				`max_header_bytes: expected an integer, got string "1KB"`,
+				"prot: unknown field",
+				"tls.hosts: expected a list of strings",
+				"tls.client_ca: expected a string, got a list",
+				"listen[1]: expected a string, got a mapping",
+			},
+		},
+		{
+			name:    "bad durations",
+			file:    "bad.json",
+			content: `{"idle_timeout": "2 minutes", "shutdown_timeout": true}`,
+			want:    []string{`idle_timeout: invalid duration "2 minutes"`, "shutdown_timeout: expected a duration"},
+		},
+		{
+			name:    "nested unknown field",
+			file:    "bad.json",
+			content: `{"raw_echo": {"mode": "line", "delay": 5}}`,
+			want:    []string{"raw_echo.delay: unknown field"},
+		},
+		{
+			name:    "bad file mode",
+			file:    "bad.toml",
+			content: "unix_socket_mode = 660\n",
+			want:    []string{`unix_socket_mode: expected an octal string such as "0660"`},
+		},
+		{
+			name:    "routes",
+			file:    "bad.yaml",
+			content: "routes:\n  - path: /a\n    status: ok\n    headers: {X-A: [b]}\n  - /b\n",
+			want: []string{
+				`routes[0].status: expected an integer, got string "ok"`,
+				"routes[0].headers.X-A: expected a string, got a list",
+				`routes[1]: expected a mapping, got string "/b"`,
+			},
+		},
+		{name: "top level", file: "bad.yaml", content: "- port\n", want: []string{"top level must be a mapping"}},
+		{name: "YAML syntax", file: "bad.yml", content: "tls:\n  port: 1\n    hosts: x\n", want: []string{"line 3"}},
+		{name: "JSON syntax", file: "bad.json", content: `{"port": }`, want: []string{"bad.json"}},
+		{name: "TOML syntax", file: "bad.toml", content: "port = 1\nport = 2\n", want: []string{"line 2", "already been defined"}},
+		{name: "unknown format", file: "echobox.ini", content: "port=1", want: []string{"unsupported format"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Default().LoadFile(writeConfig(t, tt.file, tt.content))
+			if err == nil {
+				t.Fatal("LoadFile() error = nil, want error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("LoadFile() error = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestServer_LoadFile_KeepsValuesOnError(t *testing.T) {
+	cfg := Default()
+	cfg.LoadFile(writeConfig(t, "partial.yaml", "port: 9000\nwrite_timeout: soon\n"))
+
+	if cfg.Port != "9000" {
+		t.Errorf("Port = %v, want valid values applied", cfg.Port)
+	}
+	if cfg.WriteTimeout != DefaultWriteTimeout {
+		t.Errorf("WriteTimeout = %v, want the default kept", cfg.WriteTimeout)
+	}
+}
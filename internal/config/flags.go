@@ -0,0 +1,126 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// RegisterFlags binds every setting of s to a flag on fs. The current values
+// of s become the flag defaults, so calling it on the result of Load gives
+// flags precedence over environment variables, and both over built-in
+// defaults.
+func (s *Server) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&s.Port, "port", s.Port, "HTTP port (env PORT)")
+	fs.Var((*listValue)(&s.Listen), "listen", "comma-separated listen addresses, used instead of --port (env LISTEN)")
+	fs.Var((*fileModeValue)(&s.UnixSocketMode), "unix-socket-mode", "permissions for Unix socket listeners, octal (env UNIX_SOCKET_MODE)")
+	fs.Var((*durationValue)(&s.ReadTimeout), "read-timeout", "time to read a whole request, e.g. 30s; bare numbers are seconds (env READ_TIMEOUT)")
+	fs.Var((*durationValue)(&s.ReadHeaderTimeout), "read-header-timeout", "time to read request headers, 0 uses --read-timeout (env READ_HEADER_TIMEOUT)")
+	fs.Var((*durationValue)(&s.WriteTimeout), "write-timeout", "time to write a response (env WRITE_TIMEOUT)")
+	fs.Var((*durationValue)(&s.IdleTimeout), "idle-timeout", "time to keep an idle connection open, 0 uses --read-timeout (env IDLE_TIMEOUT)")
+	fs.Var((*durationValue)(&s.ShutdownDelay), "shutdown-delay", "time to keep serving with /_ready failing before shutting down (env SHUTDOWN_DELAY)")
+	fs.Var((*durationValue)(&s.ShutdownTimeout), "shutdown-timeout", "time allowed for in-flight requests on shutdown (env SHUTDOWN_TIMEOUT)")
+	fs.IntVar(&s.MaxHeaderBytes, "max-header-bytes", s.MaxHeaderBytes, "maximum size of request headers (env MAX_HEADER_BYTES)")
+	fs.BoolVar(&s.KeepAlive, "keep-alive", s.KeepAlive, "reuse connections for several requests (env KEEP_ALIVE)")
+	fs.IntVar(&s.MaxBodyBytes, "max-body-bytes", s.MaxBodyBytes, "reject larger request bodies with 413, 0 for unlimited (env MAX_BODY_BYTES)")
+	fs.IntVar(&s.BodyEchoLimit, "body-echo-limit", s.BodyEchoLimit, "echo only this many bytes of larger bodies with their size and digest, 0 for all (env BODY_ECHO_LIMIT)")
+	fs.BoolVar(&s.H2C, "h2c", s.H2C, "accept cleartext HTTP/2 (env H2C)")
+	fs.BoolVar(&s.Metrics, "metrics", s.Metrics, "serve Prometheus metrics at /metrics (env METRICS)")
+	fs.BoolVar(&s.RawCapture, "raw-capture", s.RawCapture, "capture raw HTTP/1 request heads for /raw on plain listeners (env RAW_CAPTURE)")
+
+	fs.StringVar(&s.RawEcho.TCPPort, "tcp-echo-port", s.RawEcho.TCPPort, "port for the raw TCP echo listener (env TCP_ECHO_PORT)")
+	fs.StringVar(&s.RawEcho.UDPPort, "udp-echo-port", s.RawEcho.UDPPort, "port for the raw UDP echo listener (env UDP_ECHO_PORT)")
+	fs.StringVar(&s.RawEcho.Mode, "raw-echo-mode", s.RawEcho.Mode, "raw or line (env RAW_ECHO_MODE)")
+	fs.BoolVar(&s.RawEcho.HexDump, "raw-echo-hexdump", s.RawEcho.HexDump, "log a hex dump of every received chunk (env RAW_ECHO_HEXDUMP)")
+	fs.IntVar(&s.RawEcho.DelayMs, "raw-echo-delay-ms", s.RawEcho.DelayMs, "delay before each echo in milliseconds (env RAW_ECHO_DELAY_MS)")
+	fs.IntVar(&s.RawEcho.Limit, "raw-echo-limit", s.RawEcho.Limit, "maximum bytes echoed per connection or datagram, 0 for unlimited (env RAW_ECHO_LIMIT)")
+
+	fs.StringVar(&s.TLS.Port, "tls-port", s.TLS.Port, "port for the HTTPS listener (env TLS_PORT)")
+	fs.StringVar(&s.TLS.CertFile, "tls-cert-file", s.TLS.CertFile, "PEM certificate (chain) for HTTPS (env TLS_CERT_FILE)")
+	fs.StringVar(&s.TLS.KeyFile, "tls-key-file", s.TLS.KeyFile, "PEM private key for HTTPS (env TLS_KEY_FILE)")
+	fs.Var((*listValue)(&s.TLS.Hosts), "tls-hosts", "comma-separated names and IPs for the generated certificate (env TLS_HOSTS)")
+	fs.StringVar(&s.TLS.ClientAuth, "tls-client-auth", s.TLS.ClientAuth, "none, request, require or verify (env TLS_CLIENT_AUTH)")
+	fs.StringVar(&s.TLS.ClientCA, "tls-client-ca", s.TLS.ClientCA, "PEM bundle used to verify client certificates (env TLS_CLIENT_CA)")
+
+	fs.BoolVar(&s.Proxy.Enabled, "proxy-protocol", s.Proxy.Enabled, "decode PROXY protocol headers (env PROXY_PROTOCOL)")
+	fs.Var((*listValue)(&s.Proxy.Trusted), "proxy-trusted", "comma-separated CIDRs or IPs allowed to send PROXY headers (env PROXY_TRUSTED)")
+
+	fs.BoolVar(&s.AccessLog.Enabled, "access-log", s.AccessLog.Enabled, "log every request to stdout (env ACCESS_LOG)")
+	fs.StringVar(&s.AccessLog.Format, "access-log-format", s.AccessLog.Format, "text, json, combined or common (env ACCESS_LOG_FORMAT)")
+	fs.StringVar(&s.AccessLog.Level, "access-log-level", s.AccessLog.Level, "minimum level: debug, info, warn or error (env ACCESS_LOG_LEVEL)")
+	fs.BoolVar(&s.AccessLog.Headers, "access-log-headers", s.AccessLog.Headers, "include request headers (env ACCESS_LOG_HEADERS)")
+	fs.IntVar(&s.AccessLog.Body, "access-log-body", s.AccessLog.Body, "include up to this many request body bytes (env ACCESS_LOG_BODY)")
+	fs.Var((*listValue)(&s.AccessLog.Redact), "access-log-redact", "comma-separated headers masked in the log (env ACCESS_LOG_REDACT)")
+
+	fs.BoolVar(&s.Admin.Enabled, "admin", s.Admin.Enabled, "serve the admin API under /_admin/ (env ADMIN)")
+	fs.StringVar(&s.Admin.Token, "admin-token", s.Admin.Token, "bearer token required by the admin API (env ADMIN_TOKEN)")
+
+	fs.StringVar(&s.Tracing.Endpoint, "otlp-endpoint", s.Tracing.Endpoint, "OTLP/HTTP JSON URL request spans are exported to (env OTLP_ENDPOINT)")
+	fs.StringVar(&s.Tracing.ServiceName, "otlp-service-name", s.Tracing.ServiceName, "service.name of exported spans (env OTLP_SERVICE_NAME)")
+
+	fs.BoolVar(&s.CORS.Enabled, "cors", s.CORS.Enabled, "answer cross-origin requests and preflights (env CORS)")
+	fs.BoolVar(&s.CORS.Reflect, "cors-reflect", s.CORS.Reflect, "allow whatever origin, method and headers the browser asks for (env CORS_REFLECT)")
+	fs.Var((*listValue)(&s.CORS.Origins), "cors-origins", "comma-separated allowed origins: *, exact, wildcard or /regexp/ (env CORS_ORIGINS)")
+	fs.Var((*listValue)(&s.CORS.Methods), "cors-methods", "comma-separated methods allowed in preflights, empty for any (env CORS_METHODS)")
+	fs.Var((*listValue)(&s.CORS.Headers), "cors-headers", "comma-separated headers allowed in preflights, empty for any (env CORS_HEADERS)")
+	fs.Var((*listValue)(&s.CORS.ExposeHeaders), "cors-expose-headers", "comma-separated response headers scripts may read (env CORS_EXPOSE_HEADERS)")
+	fs.BoolVar(&s.CORS.Credentials, "cors-credentials", s.CORS.Credentials, "allow cookies and authorization (env CORS_CREDENTIALS)")
+	fs.Var((*durationValue)(&s.CORS.MaxAge), "cors-max-age", "time browsers may cache preflight results (env CORS_MAX_AGE)")
+
+	fs.IntVar(&s.Upload.MaxPartBytes, "upload-max-part-bytes", s.Upload.MaxPartBytes, "reject uploads with a larger part with 413, 0 for unlimited (env UPLOAD_MAX_PART_BYTES)")
+	fs.IntVar(&s.Upload.MaxParts, "upload-max-parts", s.Upload.MaxParts, "reject uploads with more parts with 413, 0 for unlimited (env UPLOAD_MAX_PARTS)")
+}
+
+// listValue is a comma-separated flag. Setting it replaces the whole list,
+// including any value taken from the environment.
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(s string) error {
+	*l = splitList(s)
+	return nil
+}
+
+type durationValue time.Duration
+
+func (d *durationValue) String() string {
+	if d == nil {
+		return ""
+	}
+	return time.Duration(*d).String()
+}
+
+func (d *durationValue) Set(s string) error {
+	val, err := ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", s)
+	}
+	*d = durationValue(val)
+	return nil
+}
+
+type fileModeValue os.FileMode
+
+func (m *fileModeValue) String() string {
+	if m == nil {
+		return ""
+	}
+	return fmt.Sprintf("%#o", os.FileMode(*m))
+}
+
+func (m *fileModeValue) Set(s string) error {
+	mode, err := parseFileMode(s)
+	if err != nil {
+		return err
+	}
+	*m = fileModeValue(mode)
+	return nil
+}
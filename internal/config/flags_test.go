@@ -0,0 +1,75 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRegisterFlags_Precedence(t *testing.T) {
+	os.Setenv("PORT", "7000")
+	os.Setenv("READ_TIMEOUT", "45")
+	defer os.Unsetenv("PORT")
+	defer os.Unsetenv("READ_TIMEOUT")
+
+	cfg := Load()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+
+	if err := fs.Parse([]string{"--port", "9000", "--h2c=false", "--idle-timeout", "750ms", "--keep-alive=false"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if cfg.Port != "9000" {
+		t.Errorf("Port = %v, want flag to override PORT", cfg.Port)
+	}
+	if cfg.ReadTimeout != 45*time.Second {
+		t.Errorf("ReadTimeout = %v, want READ_TIMEOUT without a flag", cfg.ReadTimeout)
+	}
+	if cfg.WriteTimeout != DefaultWriteTimeout {
+		t.Errorf("WriteTimeout = %v, want default %v", cfg.WriteTimeout, DefaultWriteTimeout)
+	}
+	if cfg.H2C {
+		t.Error("H2C = true, want --h2c=false to disable it")
+	}
+	if cfg.IdleTimeout != 750*time.Millisecond || cfg.KeepAlive {
+		t.Errorf("IdleTimeout = %v KeepAlive = %v, want 750ms and false", cfg.IdleTimeout, cfg.KeepAlive)
+	}
+}
+
+func TestRegisterFlags_Values(t *testing.T) {
+	os.Setenv("TLS_HOSTS", "env.example")
+	defer os.Unsetenv("TLS_HOSTS")
+
+	cfg := Load()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+
+	err := fs.Parse([]string{
+		"--listen", ":8080, unix:///tmp/echobox.sock",
+		"--unix-socket-mode", "0600",
+		"--tls-hosts", "a.example,b.example",
+	})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if len(cfg.Listen) != 2 || cfg.Listen[1] != "unix:///tmp/echobox.sock" {
+		t.Errorf("Listen = %v, want two addresses", cfg.Listen)
+	}
+	if cfg.UnixSocketMode != 0o600 {
+		t.Errorf("UnixSocketMode = %v, want 0600", cfg.UnixSocketMode)
+	}
+	if len(cfg.TLS.Hosts) != 2 || cfg.TLS.Hosts[0] != "a.example" {
+		t.Errorf("TLS.Hosts = %v, want the flag to replace TLS_HOSTS", cfg.TLS.Hosts)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	Load().RegisterFlags(fs)
+	if err := fs.Parse([]string{"--unix-socket-mode", "rw"}); err == nil {
+		t.Error("Parse() error = nil, want error for non-octal mode")
+	}
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Elagoht/echobox/internal/listener"
+	"github.com/Elagoht/echobox/internal/proxyproto"
+)
+
+// Validate reports every problem at once: values that failed to load from
+// the configuration file or environment, then invalid settings named by
+// their field path. It runs before any listener is opened.
+func (s *Server) Validate() error {
+	errs := append([]error(nil), s.loadErrs...)
+	check := func(path string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+
+	if len(s.Listen) == 0 {
+		check("port", validatePort(s.Port))
+	}
+	for i, addr := range s.Listen {
+		_, err := listener.Parse(addr)
+		check(fmt.Sprintf("listen[%d]", i), err)
+	}
+	for _, d := range []struct {
+		path  string
+		value time.Duration
+	}{
+		{"read_timeout", s.ReadTimeout},
+		{"read_header_timeout", s.ReadHeaderTimeout},
+		{"write_timeout", s.WriteTimeout},
+		{"idle_timeout", s.IdleTimeout},
+		{"shutdown_delay", s.ShutdownDelay},
+		{"shutdown_timeout", s.ShutdownTimeout},
+		{"cors.max_age", s.CORS.MaxAge},
+	} {
+		if d.value < 0 {
+			check(d.path, fmt.Errorf("must not be negative, got %v", d.value))
+		}
+	}
+	check("max_header_bytes", validateNonNegative(s.MaxHeaderBytes))
+	check("max_body_bytes", validateNonNegative(s.MaxBodyBytes))
+	check("body_echo_limit", validateNonNegative(s.BodyEchoLimit))
+
+	if s.RawEcho.TCPPort != "" {
+		check("raw_echo.tcp_port", validatePort(s.RawEcho.TCPPort))
+	}
+	if s.RawEcho.UDPPort != "" {
+		check("raw_echo.udp_port", validatePort(s.RawEcho.UDPPort))
+	}
+	if s.RawEcho.Mode != "raw" && s.RawEcho.Mode != "line" {
+		check("raw_echo.mode", fmt.Errorf("must be raw or line, got %q", s.RawEcho.Mode))
+	}
+	check("raw_echo.delay_ms", validateNonNegative(s.RawEcho.DelayMs))
+	check("raw_echo.limit", validateNonNegative(s.RawEcho.Limit))
+
+	if s.TLS.Port != "" {
+		check("tls.port", validatePort(s.TLS.Port))
+	}
+	switch s.TLS.ClientAuth {
+	case "none", "request", "require", "verify":
+	default:
+		check("tls.client_auth", fmt.Errorf("must be none, request, require or verify, got %q", s.TLS.ClientAuth))
+	}
+	if s.TLS.ClientAuth == "verify" && s.TLS.ClientCA == "" {
+		check("tls.client_ca", errors.New("must be set when tls.client_auth is verify"))
+	}
+	if (s.TLS.CertFile == "") != (s.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("tls.cert_file and tls.key_file must be set together"))
+	}
+
+	if s.Proxy.Enabled && len(s.Proxy.Trusted) == 0 {
+		// Otherwise any client could send a header and forge its address
+		check("proxy.trusted", errors.New("must list the networks allowed to send PROXY headers"))
+	}
+	for i, network := range s.Proxy.Trusted {
+		_, err := proxyproto.ParseCIDRs([]string{network})
+		check(fmt.Sprintf("proxy.trusted[%d]", i), err)
+	}
+
+	switch s.AccessLog.Format {
+	case "text", "json", "combined", "common":
+	default:
+		check("access_log.format", fmt.Errorf("must be text, json, combined or common, got %q", s.AccessLog.Format))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s.AccessLog.Level)); err != nil {
+		check("access_log.level", fmt.Errorf("must be debug, info, warn or error, got %q", s.AccessLog.Level))
+	}
+	check("access_log.body", validateNonNegative(s.AccessLog.Body))
+
+	check("upload.max_part_bytes", validateNonNegative(s.Upload.MaxPartBytes))
+	check("upload.max_parts", validateNonNegative(s.Upload.MaxParts))
+
+	paths := make(map[string]bool)
+	for i, r := range s.Routes {
+		path := fmt.Sprintf("routes[%d]", i)
+		switch {
+		case !strings.HasPrefix(r.Path, "/"):
+			check(path+".path", fmt.Errorf("must start with /, got %q", r.Path))
+		case paths[r.Path]:
+			check(path+".path", fmt.Errorf("%q is already routed", r.Path))
+		}
+		paths[r.Path] = true
+		if r.Status != 0 && (r.Status < 200 || r.Status > 699) {
+			check(path+".status", fmt.Errorf("must be between 200 and 699, got %d", r.Status))
+		}
+	}
+
+	if s.Tracing.Endpoint != "" {
+		check("tracing.endpoint", validateURL(s.Tracing.Endpoint))
+		if s.Tracing.ServiceName == "" {
+			check("tracing.service_name", errors.New("must not be empty"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("must be an http or https URL, got %q", s)
+	}
+	return nil
+}
+
+func validatePort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+func validateNonNegative(n int) error {
+	if n < 0 {
+		return fmt.Errorf("must not be negative, got %d", n)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Server)
+		wantErr string
+	}{
+		{name: "defaults", modify: func(s *Server) {}},
+		{name: "listen replaces port", modify: func(s *Server) { s.Port = ""; s.Listen = []string{"unix:///tmp/x.sock"} }},
+		{name: "invalid port", modify: func(s *Server) { s.Port = "invalid" }, wantErr: "port"},
+		{name: "port out of range", modify: func(s *Server) { s.Port = "70000" }, wantErr: "port"},
+		{name: "invalid listen", modify: func(s *Server) { s.Listen = []string{"udp://:53"} }, wantErr: "listen[0]"},
+		{name: "negative timeout", modify: func(s *Server) { s.ReadTimeout = -1 }, wantErr: "read_timeout"},
+		{name: "negative shutdown delay", modify: func(s *Server) { s.ShutdownDelay = -time.Second }, wantErr: "shutdown_delay"},
+		{name: "negative shutdown timeout", modify: func(s *Server) { s.ShutdownTimeout = -time.Second }, wantErr: "shutdown_timeout"},
+		{name: "negative header limit", modify: func(s *Server) { s.MaxHeaderBytes = -1 }, wantErr: "max_header_bytes"},
+		{name: "invalid raw echo mode", modify: func(s *Server) { s.RawEcho.Mode = "words" }, wantErr: "raw_echo.mode"},
+		{name: "invalid TCP echo port", modify: func(s *Server) { s.RawEcho.TCPPort = "x" }, wantErr: "raw_echo.tcp_port"},
+		{name: "tracing endpoint", modify: func(s *Server) { s.Tracing.Endpoint = "http://localhost:4318/v1/traces" }},
+		{name: "invalid tracing endpoint", modify: func(s *Server) { s.Tracing.Endpoint = "localhost:4318" }, wantErr: "tracing.endpoint"},
+		{name: "empty service name", modify: func(s *Server) { s.Tracing = Tracing{Endpoint: "http://c/v1/traces"} }, wantErr: "tracing.service_name"},
+		{name: "invalid client auth", modify: func(s *Server) { s.TLS.ClientAuth = "always" }, wantErr: "tls.client_auth"},
+		{name: "verify with client CA", modify: func(s *Server) { s.TLS.ClientAuth = "verify"; s.TLS.ClientCA = "clients.pem" }},
+		{name: "verify without client CA", modify: func(s *Server) { s.TLS.ClientAuth = "verify" }, wantErr: "tls.client_ca"},
+		{name: "cert without key", modify: func(s *Server) { s.TLS.CertFile = "cert.pem" }, wantErr: "tls.key_file"},
+		{name: "invalid access log format", modify: func(s *Server) { s.AccessLog.Format = "xml" }, wantErr: "access_log.format"},
+		{name: "invalid access log level", modify: func(s *Server) { s.AccessLog.Level = "loud" }, wantErr: "access_log.level"},
+		{name: "negative upload part limit", modify: func(s *Server) { s.Upload.MaxPartBytes = -1 }, wantErr: "upload.max_part_bytes"},
+		{name: "negative upload part count", modify: func(s *Server) { s.Upload.MaxParts = -1 }, wantErr: "upload.max_parts"},
+		{name: "routes", modify: func(s *Server) { s.Routes = []Route{{Path: "/"}, {Path: "/headers", Status: 418}} }},
+		{name: "route without slash", modify: func(s *Server) { s.Routes = []Route{{Path: "users"}} }, wantErr: "routes[0].path"},
+		{name: "duplicate route", modify: func(s *Server) { s.Routes = []Route{{Path: "/a"}, {Path: "/a"}} }, wantErr: "routes[1].path"},
+		{name: "invalid route status", modify: func(s *Server) { s.Routes = []Route{{Path: "/a", Status: 99}} }, wantErr: "routes[0].status"},
+		{name: "proxy protocol", modify: func(s *Server) { s.Proxy = Proxy{Enabled: true, Trusted: []string{"10.0.0.0/8"}} }},
+		{name: "proxy protocol without trusted networks", modify: func(s *Server) { s.Proxy.Enabled = true }, wantErr: "proxy.trusted"},
+		{name: "invalid trusted network", modify: func(s *Server) { s.Proxy.Trusted = []string{"10.0.0.0/8", "nope"} }, wantErr: "proxy.trusted[1]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Default()
+			tt.modify(s)
+
+			err := s.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want mention of %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServer_Validate_ReportsAll(t *testing.T) {
+	s := Default()
+	s.Port = "invalid"
+	s.RawEcho.Mode = "words"
+
+	err := s.Validate()
+	if err == nil || !strings.Contains(err.Error(), "port") || !strings.Contains(err.Error(), "raw_echo.mode") {
+		t.Errorf("Validate() error = %v, want both problems reported", err)
+	}
+}
@@ -0,0 +1,229 @@
+package cors
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Options struct {
+	// Origins allowed to make requests: "*" for any, exact origins,
+	// wildcards such as https://*.example.com, or regular expressions
+	// between slashes such as /^https://(a|b)\.test$/.
+	Origins []string
+	// Methods allowed in preflights. Empty allows any.
+	Methods []string
+	// Headers allowed in preflights. Empty allows any the browser asks for.
+	Headers []string
+	// ExposeHeaders lists response headers scripts may read.
+	ExposeHeaders []string
+	Credentials   bool
+	// MaxAge lets browsers cache preflight results. Zero omits the header.
+	MaxAge time.Duration
+	// Reflect allows whatever the browser asks for, with credentials.
+	Reflect bool
+}
+
+// Preflight describes a preflight request and how it was answered. It is
+// the body of preflight responses, for debugging what the browser sent.
+type Preflight struct {
+	Origin         string              `json:"origin"`
+	RequestMethod  string              `json:"request_method"`
+	RequestHeaders []string            `json:"request_headers"`
+	Allowed        bool                `json:"allowed"`
+	Reason         string              `json:"reason,omitempty"`
+	Headers        map[string][]string `json:"headers"`
+}
+
+type CORS struct {
+	opts      Options
+	anyOrigin bool
+	origins   []*regexp.Regexp
+	methods   map[string]bool
+	headers   map[string]bool
+	anyHeader bool
+}
+
+func New(opts Options) (*CORS, error) {
+	origins, anyOrigin, err := CompileOrigins(opts.Origins)
+	if err != nil {
+		return nil, err
+	}
+	c := &CORS{
+		opts:      opts,
+		anyOrigin: anyOrigin || opts.Reflect,
+		origins:   origins,
+		methods:   make(map[string]bool, len(opts.Methods)),
+		headers:   make(map[string]bool, len(opts.Headers)),
+	}
+	for _, m := range opts.Methods {
+		c.methods[strings.ToUpper(m)] = true
+	}
+	for _, h := range opts.Headers {
+		if h == "*" {
+			c.anyHeader = true
+		}
+		c.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	return c, nil
+}
+
+// CompileOrigins turns origin patterns into expressions matched against the
+// whole Origin header, reporting whether "*" allows any origin.
+func CompileOrigins(patterns []string) ([]*regexp.Regexp, bool, error) {
+	var (
+		compiled  []*regexp.Regexp
+		anyOrigin bool
+	)
+	for _, p := range patterns {
+		var expr string
+		switch {
+		case p == "*":
+			anyOrigin = true
+			continue
+		case len(p) > 2 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/"):
+			// Anchored, so a pattern cannot be satisfied by a prefix or
+			// suffix of an attacker's origin
+			expr = "^(?:" + p[1:len(p)-1] + ")$"
+		default:
+			parts := strings.Split(p, "*")
+			for i := range parts {
+				parts[i] = regexp.QuoteMeta(parts[i])
+			}
+			expr = "(?i)^" + strings.Join(parts, "[^/]*") + "$"
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, false, fmt.Errorf("origin %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, anyOrigin, nil
+}
+
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			c.preflight(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		if c.allowOrigin(origin) {
+			c.setOrigin(h, origin)
+			if len(c.opts.ExposeHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(c.opts.ExposeHeaders, ", "))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *CORS) preflight(w http.ResponseWriter, r *http.Request) {
+	p := Preflight{
+		Origin:         r.Header.Get("Origin"),
+		RequestMethod:  r.Header.Get("Access-Control-Request-Method"),
+		RequestHeaders: requestedHeaders(r.Header),
+		Headers:        r.Header,
+	}
+
+	h := w.Header()
+	h.Add("Vary", "Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+
+	switch {
+	case !c.allowOrigin(p.Origin):
+		p.Reason = fmt.Sprintf("origin %s is not allowed", p.Origin)
+	case !c.allowMethod(p.RequestMethod):
+		p.Reason = fmt.Sprintf("method %s is not allowed", p.RequestMethod)
+	default:
+		p.Allowed = true
+		for _, name := range p.RequestHeaders {
+			if !c.allowHeader(name) {
+				p.Allowed = false
+				p.Reason = fmt.Sprintf("header %s is not allowed", name)
+				break
+			}
+		}
+	}
+
+	status := http.StatusForbidden
+	if p.Allowed {
+		status = http.StatusOK
+		c.setOrigin(h, p.Origin)
+		if c.opts.Reflect || len(c.methods) == 0 {
+			h.Set("Access-Control-Allow-Methods", p.RequestMethod)
+		} else {
+			h.Set("Access-Control-Allow-Methods", strings.Join(c.opts.Methods, ", "))
+		}
+		if len(p.RequestHeaders) > 0 {
+			h.Set("Access-Control-Allow-Headers", strings.Join(p.RequestHeaders, ", "))
+		}
+		if c.opts.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.opts.MaxAge.Seconds())))
+		}
+	}
+
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Printf("Error encoding preflight: %v", err)
+	}
+}
+
+// setOrigin allows origin, naming it rather than "*" when credentials are
+// allowed, as browsers require.
+func (c *CORS) setOrigin(h http.Header, origin string) {
+	credentials := c.opts.Credentials || c.opts.Reflect
+	if c.anyOrigin && !credentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *CORS) allowOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	for _, re := range c.origins {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORS) allowMethod(method string) bool {
+	return c.opts.Reflect || len(c.methods) == 0 || c.methods[method]
+}
+
+func (c *CORS) allowHeader(name string) bool {
+	return c.opts.Reflect || len(c.headers) == 0 || c.anyHeader || c.headers[http.CanonicalHeaderKey(name)]
+}
+
+func requestedHeaders(h http.Header) []string {
+	var names []string
+	for _, v := range h.Values("Access-Control-Request-Headers") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
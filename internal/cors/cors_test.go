@@ -0,0 +1,175 @@
+package cors
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("echo"))
+})
+
+func TestCompileOrigins(t *testing.T) {
+	tests := []struct {
+		pattern string
+		origin  string
+		want    bool
+	}{
+		{"https://app.test", "https://app.test", true},
+		{"https://app.test", "HTTPS://APP.TEST", true},
+		{"https://app.test", "https://app.test.evil", false},
+		{"https://*.example.com", "https://a.example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"https://*.example.com", "https://a.example.com.evil/x", false},
+		{"http://localhost:*", "http://localhost:3000", true},
+		{`/^https://(a|b)\.test$/`, "https://b.test", true},
+		{`/^https://(a|b)\.test$/`, "https://c.test", false},
+		{`/https://app\.example\.com/`, "https://app.example.com", true},
+		{`/https://app\.example\.com/`, "https://app.example.com.attacker.net", false},
+		{`/https://app\.example\.com/`, "https://evil.test?https://app.example.com", false},
+		{`/https://a\.test|https://b\.test/`, "https://b.test.evil", false},
+	}
+
+	for _, tt := range tests {
+		res, anyOrigin, err := CompileOrigins([]string{tt.pattern})
+		if err != nil || anyOrigin {
+			t.Fatalf("CompileOrigins(%q) = %v, %v", tt.pattern, anyOrigin, err)
+		}
+		if got := res[0].MatchString(tt.origin); got != tt.want {
+			t.Errorf("origin %q against %q = %v, want %v", tt.origin, tt.pattern, got, tt.want)
+		}
+	}
+
+	if _, anyOrigin, _ := CompileOrigins([]string{"*"}); !anyOrigin {
+		t.Error("CompileOrigins(*) any = false, want true")
+	}
+	if _, _, err := CompileOrigins([]string{"/(/"}); err == nil {
+		t.Error("CompileOrigins(/(/) error = nil, want an invalid regexp error")
+	}
+}
+
+func TestCORS_Simple(t *testing.T) {
+	tests := []struct {
+		name            string
+		opts            Options
+		origin          string
+		wantOrigin      string
+		wantCredentials string
+	}{
+		{"no origin", Options{Origins: []string{"*"}}, "", "", ""},
+		{"any origin", Options{Origins: []string{"*"}}, "https://a.test", "*", ""},
+		{"any origin with credentials", Options{Origins: []string{"*"}, Credentials: true}, "https://a.test", "https://a.test", "true"},
+		{"listed origin", Options{Origins: []string{"https://a.test"}}, "https://a.test", "https://a.test", ""},
+		{"unlisted origin", Options{Origins: []string{"https://a.test"}}, "https://b.test", "", ""},
+		{"reflect", Options{Reflect: true}, "https://b.test", "https://b.test", "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(tt.opts)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			c.Handler(ok).ServeHTTP(w, req)
+
+			if w.Body.String() != "echo" {
+				t.Errorf("body = %q, want the request passed through", w.Body.String())
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.wantCredentials)
+			}
+		})
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	opts := Options{
+		Origins: []string{"https://*.app.test"},
+		Methods: []string{"GET", "PUT"},
+		Headers: []string{"Content-Type", "X-Token"},
+		MaxAge:  10 * time.Minute,
+	}
+
+	tests := []struct {
+		name        string
+		opts        Options
+		origin      string
+		method      string
+		headers     string
+		wantStatus  int
+		wantReason  string
+		wantMethods string
+		wantHeaders string
+	}{
+		{"allowed", opts, "https://web.app.test", "PUT", "content-type, x-token", http.StatusOK, "", "GET, PUT", "content-type, x-token"},
+		{"origin", opts, "https://evil.test", "PUT", "", http.StatusForbidden, "origin https://evil.test is not allowed", "", ""},
+		{"method", opts, "https://web.app.test", "DELETE", "", http.StatusForbidden, "method DELETE is not allowed", "", ""},
+		{"header", opts, "https://web.app.test", "GET", "X-Other", http.StatusForbidden, "header X-Other is not allowed", "", ""},
+		{"reflect", Options{Reflect: true}, "https://evil.test", "DELETE", "X-Other", http.StatusOK, "", "DELETE", "X-Other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := New(tt.opts)
+			req := httptest.NewRequest(http.MethodOptions, "/things", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			w := httptest.NewRecorder()
+			c.Handler(ok).ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			var p Preflight
+			if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+				t.Fatalf("Failed to decode preflight: %v", err)
+			}
+			if p.Origin != tt.origin || p.RequestMethod != tt.method || p.Allowed != (tt.wantStatus == http.StatusOK) || p.Reason != tt.wantReason {
+				t.Errorf("preflight = %+v, want origin %s, method %s, reason %q", p, tt.origin, tt.method, tt.wantReason)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Methods"); got != tt.wantMethods {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, tt.wantMethods)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Headers"); got != tt.wantHeaders {
+				t.Errorf("Access-Control-Allow-Headers = %q, want %q", got, tt.wantHeaders)
+			}
+		})
+	}
+
+	// Max-Age only accompanies allowed preflights
+	c, _ := New(opts)
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Origin", "https://web.app.test")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	w := httptest.NewRecorder()
+	c.Handler(ok).ServeHTTP(w, req)
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Access-Control-Max-Age = %q, want 600", got)
+	}
+}
+
+func TestCORS_PlainOptions(t *testing.T) {
+	c, _ := New(Options{Origins: []string{"*"}})
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Origin", "https://a.test")
+	w := httptest.NewRecorder()
+	c.Handler(ok).ServeHTTP(w, req)
+
+	if w.Body.String() != "echo" {
+		t.Errorf("OPTIONS without Access-Control-Request-Method body = %q, want it passed through", w.Body.String())
+	}
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/Elagoht/echobox/internal/health"
+)
+
+// RequireToken rejects requests without "Authorization: Bearer token". An
+// empty token lets every request through.
+func RequireToken(token string, h http.HandlerFunc) http.HandlerFunc {
+	if token == "" {
+		return h
+	}
+	want := []byte("Bearer " + token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="echobox admin"`)
+			writeError(w, http.StatusUnauthorized, ErrorResponse{Error: "invalid or missing admin token"})
+			return
+		}
+		h(w, r)
+	}
+}
+
+// AdminNotFound answers paths under /_admin/ without an endpoint, rather than
+// letting the echo answer them as if they existed.
+func AdminNotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, ErrorResponse{Error: "no admin endpoint at " + r.URL.Path})
+}
+
+// AdminHealth reads the /_health setting on GET, replaces it with the JSON
+// body on PUT or POST and resets it to healthy on DELETE:
+//
+//	{"mode": "slow", "delay": "3s"}
+//	{"mode": "flapping", "period": "10s"}
+func AdminHealth(state *health.State) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			setting health.Setting
+			err     error
+		)
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			setting = state.Setting()
+		case http.MethodPut, http.MethodPost:
+			dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&setting); err != nil {
+				writeError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid health setting: " + err.Error()})
+				return
+			}
+			if setting, err = state.Set(setting); err != nil {
+				writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+				return
+			}
+			log.Printf("Health set to %s", setting.Mode)
+		case http.MethodDelete:
+			setting, _ = state.Set(health.Setting{Mode: health.Healthy})
+			log.Printf("Health reset to %s", setting.Mode)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT, POST, DELETE")
+			writeError(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(setting); err != nil {
+			log.Printf("Error encoding health setting: %v", err)
+		}
+	}
+}
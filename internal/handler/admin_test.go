@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Elagoht/echobox/internal/health"
+)
+
+func TestAdminHealth(t *testing.T) {
+	state := health.New()
+	admin := AdminHealth(state)
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantBody   string
+		wantHealth int
+	}{
+		{"get", http.MethodGet, "", http.StatusOK, `{"mode":"healthy"}`, http.StatusOK},
+		{"set unhealthy", http.MethodPut, `{"mode":"unhealthy"}`, http.StatusOK, `{"mode":"unhealthy"}`, http.StatusServiceUnavailable},
+		{"set degraded", http.MethodPost, `{"mode":"degraded"}`, http.StatusOK, `{"mode":"degraded"}`, http.StatusOK},
+		{"unknown mode", http.MethodPut, `{"mode":"sick"}`, http.StatusBadRequest, `"error"`, http.StatusOK},
+		{"unknown field", http.MethodPut, `{"mode":"slow","delay_ms":5}`, http.StatusBadRequest, `unknown field`, http.StatusOK},
+		{"reset", http.MethodDelete, "", http.StatusOK, `{"mode":"healthy"}`, http.StatusOK},
+		{"method not allowed", http.MethodPatch, "", http.StatusMethodNotAllowed, `"error"`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			admin(w, httptest.NewRequest(tt.method, "/_admin/health", strings.NewReader(tt.body)))
+
+			if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("AdminHealth() = %d %s, want %d containing %s", w.Code, w.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+
+			w = httptest.NewRecorder()
+			Health(state)(w, httptest.NewRequest(http.MethodGet, "/_health", nil))
+			if w.Code != tt.wantHealth {
+				t.Errorf("Health() status = %v, want %v", w.Code, tt.wantHealth)
+			}
+		})
+	}
+}
+
+func TestHealth_Slow(t *testing.T) {
+	state := health.New()
+	state.Set(health.Setting{Mode: health.Slow, Delay: health.Duration(50 * time.Millisecond)})
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	Health(state)(w, httptest.NewRequest(http.MethodGet, "/_health", nil))
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || w.Code != http.StatusOK {
+		t.Errorf("Health() = %d after %v, want 200 after the delay", w.Code, elapsed)
+	}
+
+	// A client giving up ends the wait
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	state.Set(health.Setting{Mode: health.Slow, Delay: health.Duration(time.Hour)})
+	w = httptest.NewRecorder()
+	Health(state)(w, httptest.NewRequest(http.MethodGet, "/_health", nil).WithContext(ctx))
+	if w.Body.Len() != 0 {
+		t.Errorf("Health() after cancel wrote %q, want nothing", w.Body.String())
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	tests := []struct {
+		token      string
+		header     string
+		wantStatus int
+	}{
+		{"", "", http.StatusOK},
+		{"secret", "", http.StatusUnauthorized},
+		{"secret", "Bearer wrong", http.StatusUnauthorized},
+		{"secret", "Bearer secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/_admin/health", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		RequireToken(tt.token, ok)(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("RequireToken(%q) with %q status = %v, want %v", tt.token, tt.header, w.Code, tt.wantStatus)
+		}
+	}
+}
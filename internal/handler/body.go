@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+)
+
+// BodyInfo describes a request body that was echoed only in part.
+type BodyInfo struct {
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+	Truncated bool   `json:"truncated"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Limit int64  `json:"limit,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, resp ErrorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding error response: %v", err)
+	}
+}
+
+// LimitBody rejects request bodies larger than maxBytes with 413. Bodies of
+// unknown length are cut off while being read, which the wrapped handler
+// reports through writeBodyError. A maxBytes of 0 disables the limit.
+func LimitBody(maxBytes int64, h http.HandlerFunc) http.HandlerFunc {
+	if maxBytes <= 0 {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxBytes {
+			writeError(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: "request body too large", Limit: maxBytes})
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		h(w, r)
+	}
+}
+
+// writeBodyError answers a failed body read, with 413 when the body went
+// over the LimitBody limit.
+func writeBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: "request body too large", Limit: tooLarge.Limit})
+		return
+	}
+	log.Printf("Error reading body: %v", err)
+	http.Error(w, "Error reading request body", http.StatusBadRequest)
+}
+
+// readBody reads the request body. With a positive echoLimit only that many
+// bytes are kept; the rest is hashed and counted without being buffered, and
+// info describes the whole body.
+func readBody(body io.Reader, echoLimit int64) (prefix []byte, info *BodyInfo, err error) {
+	if echoLimit <= 0 {
+		prefix, err = io.ReadAll(body)
+		return prefix, nil, err
+	}
+
+	hash := sha256.New()
+	tee := io.TeeReader(body, hash)
+	if prefix, err = io.ReadAll(io.LimitReader(tee, echoLimit)); err != nil {
+		return nil, nil, err
+	}
+	rest, err := io.Copy(io.Discard, tee)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rest == 0 {
+		return prefix, nil, nil
+	}
+
+	return prefix, &BodyInfo{
+		Size:      int64(len(prefix)) + rest,
+		SHA256:    hex.EncodeToString(hash.Sum(nil)),
+		Truncated: true,
+	}, nil
+}
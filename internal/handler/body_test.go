@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       io.Reader
+		wantStatus int
+	}{
+		{name: "within limit", body: strings.NewReader("hello"), wantStatus: http.StatusOK},
+		{name: "exactly the limit", body: strings.NewReader("0123456789"), wantStatus: http.StatusOK},
+		{name: "declared length over limit", body: strings.NewReader("0123456789a"), wantStatus: http.StatusRequestEntityTooLarge},
+		// io.MultiReader hides the length, as with chunked uploads
+		{name: "unknown length over limit", body: io.MultiReader(strings.NewReader("0123456789a")), wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", tt.body)
+			w := httptest.NewRecorder()
+
+			LimitBody(10, Echo)(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("LimitBody() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusRequestEntityTooLarge {
+				return
+			}
+
+			var resp ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode error: %v", err)
+			}
+			if resp.Error != "request body too large" || resp.Limit != 10 {
+				t.Errorf("LimitBody() error = %+v, want JSON error with limit 10", resp)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("LimitBody() Content-Type = %v, want application/json", ct)
+			}
+		})
+	}
+}
+
+func TestLimitBody_Disabled(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("a", 1<<16)))
+	w := httptest.NewRecorder()
+
+	LimitBody(0, Body)(w, req)
+
+	if w.Code != http.StatusOK || w.Body.Len() != 1<<16 {
+		t.Errorf("LimitBody(0) = %v with %d bytes, want the whole body", w.Code, w.Body.Len())
+	}
+}
+
+func TestNewEcho_BodyEchoLimit(t *testing.T) {
+	body := strings.Repeat("abcdefghij", 100)
+	sum := sha256.Sum256([]byte(body))
+
+	tests := []struct {
+		name     string
+		body     string
+		wantBody string
+		wantInfo *BodyInfo
+	}{
+		{name: "small body echoed in full", body: "short", wantBody: "short"},
+		{name: "body at the limit echoed in full", body: body[:16], wantBody: body[:16]},
+		{
+			name:     "large body truncated",
+			body:     body,
+			wantBody: body[:16],
+			wantInfo: &BodyInfo{Size: 1000, SHA256: hex.EncodeToString(sum[:]), Truncated: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			NewEcho(EchoOptions{BodyEchoLimit: 16})(w, req)
+
+			var resp EchoResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode echo: %v", err)
+			}
+			if resp.Body != tt.wantBody {
+				t.Errorf("Echo body = %q, want %q", resp.Body, tt.wantBody)
+			}
+			if (resp.BodyInfo == nil) != (tt.wantInfo == nil) || resp.BodyInfo != nil && *resp.BodyInfo != *tt.wantInfo {
+				t.Errorf("Echo body_info = %+v, want %+v", resp.BodyInfo, tt.wantInfo)
+			}
+		})
+	}
+}
+
+func TestBody_Streaming(t *testing.T) {
+	server := httptest.NewServer(LimitBody(1<<20, Body))
+	defer server.Close()
+
+	// The first chunk is echoed before the rest of the body is sent
+	pr, pw := io.Pipe()
+	go pw.Write([]byte("first chunk"))
+
+	resp, err := http.Post(server.URL, "text/plain", pr)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	buf := make([]byte, len("first chunk"))
+	if _, err := io.ReadFull(resp.Body, buf); err != nil || string(buf) != "first chunk" {
+		t.Fatalf("Body() first chunk = %q, %v, want it echoed before the body ends", buf, err)
+	}
+
+	pw.Write([]byte(" and the rest"))
+	pw.Close()
+	rest, _ := io.ReadAll(resp.Body)
+	if string(rest) != " and the rest" {
+		t.Errorf("Body() rest = %q, want the remaining body", rest)
+	}
+}
+
+func TestBody_StreamOverLimit(t *testing.T) {
+	server := httptest.NewServer(LimitBody(64<<10, Body))
+	defer server.Close()
+
+	// Without a declared length the limit is only hit mid-stream, after the
+	// status was sent, so the response must be cut short
+	body := io.MultiReader(bytes.NewReader(make([]byte, 128<<10)))
+	resp, err := http.Post(server.URL, "application/octet-stream", body)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if data, err := io.ReadAll(resp.Body); err == nil && len(data) >= 128<<10 {
+		t.Errorf("Body() echoed %d bytes without error, want the stream aborted at the limit", len(data))
+	}
+}
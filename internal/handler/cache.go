@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache answers /cache and /cache/{seconds} with next, unless If-None-Match
+// or If-Modified-Since shows the client already has it. The resource last
+// changed at lastModified, which also gives it its ETag. {seconds} sets
+// max-age.
+func Cache(lastModified time.Time, next http.HandlerFunc) http.HandlerFunc {
+	lastModified = lastModified.UTC().Truncate(time.Second)
+	etag := fmt.Sprintf(`"%x"`, lastModified.Unix())
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		if s := r.PathValue("seconds"); s != "" {
+			seconds, err := strconv.Atoi(s)
+			if err != nil || seconds < 0 {
+				writeError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid max-age"})
+				return
+			}
+			h.Set("Cache-Control", "public, max-age="+s)
+		}
+		h.Set("ETag", etag)
+		h.Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+		// If-Modified-Since only counts without If-None-Match
+		if inm := r.Header.Get("If-None-Match"); inm != "" {
+			if etagMatch(inm, etag, false) {
+				notModified(w)
+				return
+			}
+		} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.After(since) {
+			notModified(w)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ETag answers /etag/{etag} with next as a resource tagged {etag}, weak with
+// ?weak=true. A failing If-Match gets 412; a matching If-None-Match gets 304
+// for GET and HEAD and 412 otherwise. An {etag} that cannot go between the
+// quotes of an entity tag gets 400.
+func ETag(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		value := r.PathValue("etag")
+		if !validETag(value) {
+			writeError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid entity tag: " + strconv.Quote(value)})
+			return
+		}
+		etag := `"` + value + `"`
+		if weak, _ := strconv.ParseBool(r.URL.Query().Get("weak")); weak {
+			etag = "W/" + etag
+		}
+		w.Header().Set("ETag", etag)
+
+		if im := r.Header.Get("If-Match"); im != "" && !etagMatch(im, etag, true) {
+			writeError(w, http.StatusPreconditionFailed, ErrorResponse{Error: "If-Match does not match " + etag})
+			return
+		}
+		if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatch(inm, etag, false) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				notModified(w)
+				return
+			}
+			writeError(w, http.StatusPreconditionFailed, ErrorResponse{Error: "If-None-Match matches " + etag})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// validETag reports whether every byte of s is an etagc of RFC 9110: no
+// double quote, space or control character.
+func validETag(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c != 0x21 && (c < 0x23 || c == 0x7f) {
+			return false
+		}
+	}
+	return true
+}
+
+// etagMatch reports whether the list of entity tags in header matches etag.
+// Weak comparison ignores W/ prefixes; strong comparison never matches a
+// weak tag. A malformed list matches nothing.
+func etagMatch(header, etag string, strong bool) bool {
+	tags, ok := parseETags(header)
+	if !ok {
+		return false
+	}
+	for _, tag := range tags {
+		switch {
+		case tag == "*":
+			return true
+		case strong:
+			if tag == etag && !strings.HasPrefix(tag, "W/") {
+				return true
+			}
+		case strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/"):
+			return true
+		}
+	}
+	return false
+}
+
+// parseETags splits an If-Match or If-None-Match list into "*" and entity
+// tags, keeping W/ prefixes. Commas inside quotes belong to their tag; empty
+// list elements are skipped.
+func parseETags(header string) ([]string, bool) {
+	var tags []string
+	rest := header
+	for {
+		rest = strings.TrimLeft(rest, " \t,")
+		if rest == "" {
+			return tags, true
+		}
+
+		var tag string
+		if rest[0] == '*' {
+			tag, rest = "*", rest[1:]
+		} else {
+			start := 0
+			if strings.HasPrefix(rest, "W/") {
+				start = 2
+			}
+			if len(rest) <= start || rest[start] != '"' {
+				return nil, false
+			}
+			end := strings.IndexByte(rest[start+1:], '"')
+			if end < 0 || !validETag(rest[start+1:start+1+end]) {
+				return nil, false
+			}
+			tag, rest = rest[:start+end+2], rest[start+end+2:]
+		}
+		tags = append(tags, tag)
+
+		rest = strings.TrimLeft(rest, " \t")
+		if rest != "" && rest[0] != ',' {
+			return nil, false
+		}
+	}
+}
+
+// notModified answers 304, keeping the validators already set and dropping
+// headers describing a body.
+func notModified(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// ResponseHeaders sets every query parameter as a response header and
+// answers with them as JSON. A Content-Type among them replaces
+// application/json. Headers net/http manages itself are left out.
+func ResponseHeaders(w http.ResponseWriter, r *http.Request) {
+	set := map[string][]string{}
+	for name, values := range r.URL.Query() {
+		name = http.CanonicalHeaderKey(name)
+		switch name {
+		case "Content-Length", "Transfer-Encoding", "Connection", "Trailer":
+			continue
+		}
+		for _, v := range values {
+			w.Header().Add(name, v)
+		}
+		set[name] = append(set[name], values...)
+	}
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if err := json.NewEncoder(w).Encode(set); err != nil {
+		log.Printf("Error encoding headers: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+	h := Cache(modified, Echo)
+	etag := cacheETag(t, h)
+
+	tests := []struct {
+		name       string
+		seconds    string
+		header     []string
+		wantStatus int
+		wantCache  string
+	}{
+		{"fresh", "", nil, http.StatusOK, ""},
+		{"max-age", "60", nil, http.StatusOK, "public, max-age=60"},
+		{"if-none-match", "", []string{"If-None-Match", etag}, http.StatusNotModified, ""},
+		{"if-none-match weak", "", []string{"If-None-Match", `"x", W/` + etag}, http.StatusNotModified, ""},
+		{"if-none-match star", "30", []string{"If-None-Match", "*"}, http.StatusNotModified, "public, max-age=30"},
+		{"if-none-match other", "", []string{"If-None-Match", `"x"`}, http.StatusOK, ""},
+		{"if-modified-since same", "", []string{"If-Modified-Since", "Wed, 01 May 2024 12:00:00 GMT"}, http.StatusNotModified, ""},
+		{"if-modified-since later", "", []string{"If-Modified-Since", "Thu, 02 May 2024 12:00:00 GMT"}, http.StatusNotModified, ""},
+		{"if-modified-since earlier", "", []string{"If-Modified-Since", "Tue, 30 Apr 2024 12:00:00 GMT"}, http.StatusOK, ""},
+		{"if-none-match wins", "", []string{"If-None-Match", `"x"`, "If-Modified-Since", "Thu, 02 May 2024 12:00:00 GMT"}, http.StatusOK, ""},
+		{"invalid max-age", "soon", nil, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := generate(h, "/cache", "seconds", tt.seconds, tt.header...)
+			if w.Code != tt.wantStatus {
+				t.Errorf("Cache() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("Cache() Cache-Control = %q, want %q", got, tt.wantCache)
+			}
+			if tt.wantStatus == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("Cache() 304 body = %q, want none", w.Body.String())
+			}
+			if tt.wantStatus != http.StatusBadRequest && w.Header().Get("Last-Modified") != "Wed, 01 May 2024 12:00:00 GMT" {
+				t.Errorf("Cache() Last-Modified = %q, want the modification time", w.Header().Get("Last-Modified"))
+			}
+		})
+	}
+}
+
+// cacheETag returns the ETag h sends.
+func cacheETag(t *testing.T, h http.HandlerFunc) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/cache", nil))
+	etag := w.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("Cache() ETag = %q, want a strong tag", etag)
+	}
+	return etag
+}
+
+func TestETag(t *testing.T) {
+	h := ETag(Echo)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		header     []string
+		wantStatus int
+		wantETag   string
+	}{
+		{"plain", http.MethodGet, "/etag/abc", nil, http.StatusOK, `"abc"`},
+		{"weak", http.MethodGet, "/etag/abc?weak=true", nil, http.StatusOK, `W/"abc"`},
+		{"if-none-match", http.MethodGet, "/etag/abc", []string{"If-None-Match", `"x", "abc"`}, http.StatusNotModified, `"abc"`},
+		{"if-none-match weak", http.MethodGet, "/etag/abc?weak=1", []string{"If-None-Match", `"abc"`}, http.StatusNotModified, `W/"abc"`},
+		{"if-none-match put", http.MethodPut, "/etag/abc", []string{"If-None-Match", "*"}, http.StatusPreconditionFailed, `"abc"`},
+		{"if-match", http.MethodPut, "/etag/abc", []string{"If-Match", `"abc"`}, http.StatusOK, `"abc"`},
+		{"if-match star", http.MethodDelete, "/etag/abc", []string{"If-Match", "*"}, http.StatusOK, `"abc"`},
+		{"if-match other", http.MethodPut, "/etag/abc", []string{"If-Match", `"x"`}, http.StatusPreconditionFailed, `"abc"`},
+		{"if-match weak tag", http.MethodPut, "/etag/abc", []string{"If-Match", `W/"abc"`}, http.StatusPreconditionFailed, `"abc"`},
+		{"if-match weak resource", http.MethodPut, "/etag/abc?weak=true", []string{"If-Match", `W/"abc"`}, http.StatusPreconditionFailed, `W/"abc"`},
+		{"if-match first", http.MethodGet, "/etag/abc", []string{"If-Match", `"x"`, "If-None-Match", `"abc"`}, http.StatusPreconditionFailed, `"abc"`},
+		{"comma inside tag", http.MethodGet, "/etag/a,b", []string{"If-None-Match", `"x", "a,b"`}, http.StatusNotModified, `"a,b"`},
+		{"comma splits no tag", http.MethodGet, "/etag/b", []string{"If-None-Match", `"a,b"`}, http.StatusOK, `"b"`},
+		{"malformed if-match", http.MethodPut, "/etag/abc", []string{"If-Match", `abc`}, http.StatusPreconditionFailed, `"abc"`},
+		{"malformed if-none-match", http.MethodGet, "/etag/abc", []string{"If-None-Match", `"abc`}, http.StatusOK, `"abc"`},
+		{"quote", http.MethodGet, `/etag/a"b`, nil, http.StatusBadRequest, ""},
+		{"space", http.MethodGet, "/etag/a%20b", nil, http.StatusBadRequest, ""},
+		{"backslash", http.MethodGet, `/etag/a\b`, nil, http.StatusOK, `"a\b"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			req.SetPathValue("etag", strings.TrimPrefix(req.URL.Path, "/etag/"))
+			for i := 0; i+1 < len(tt.header); i += 2 {
+				req.Header.Set(tt.header[i], tt.header[i+1])
+			}
+			w := httptest.NewRecorder()
+			h(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("ETag() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag() ETag = %q, want %q", got, tt.wantETag)
+			}
+		})
+	}
+}
+
+func TestParseETags(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+		wantOK bool
+	}{
+		{`"a"`, []string{`"a"`}, true},
+		{`*`, []string{"*"}, true},
+		{` "a" ,W/"b,c",, ""`, []string{`"a"`, `W/"b,c"`, `""`}, true},
+		{``, nil, true},
+		{`a`, nil, false},
+		{`"a`, nil, false},
+		{`"a" "b"`, nil, false},
+		{`W/a`, nil, false},
+		{"\"a\x01\"", nil, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseETags(tt.header)
+		if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseETags(%q) = %q, %v, want %q, %v", tt.header, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestResponseHeaders(t *testing.T) {
+	w := httptest.NewRecorder()
+	ResponseHeaders(w, httptest.NewRequest(http.MethodGet, "/response-headers?cache-control=no-store&X-Multi=a&X-Multi=b&Content-Length=5", nil))
+
+	if got := w.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("ResponseHeaders() Cache-Control = %q, want no-store", got)
+	}
+	if got := w.Header().Values("X-Multi"); len(got) != 2 {
+		t.Errorf("ResponseHeaders() X-Multi = %v, want both values", got)
+	}
+	if w.Header().Get("Content-Length") != "" {
+		t.Error("ResponseHeaders() set Content-Length, want it left to net/http")
+	}
+	if w.Header().Get("Content-Type") != "application/json" || !strings.Contains(w.Body.String(), `"Cache-Control":["no-store"]`) {
+		t.Errorf("ResponseHeaders() = %q %q, want the headers as JSON", w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	ResponseHeaders(w, httptest.NewRequest(http.MethodGet, "/response-headers?Content-Type=text/plain", nil))
+	if got := w.Header().Get("Content-Type"); got != "text/plain" {
+		t.Errorf("ResponseHeaders() Content-Type = %q, want text/plain", got)
+	}
+}
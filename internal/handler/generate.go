@@ -0,0 +1,259 @@
+package handler
+
+import (
+	crand "crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Elagoht/echobox/internal/render"
+)
+
+// MaxGenerateBytes bounds the size of generated responses.
+const MaxGenerateBytes = 100 << 20
+
+// DefaultChunkSize is the size of each write of /stream-bytes.
+const DefaultChunkSize = 10 * 1024
+
+// byteCount parses the {n} path value, answering 400 when it is invalid or
+// over MaxGenerateBytes.
+func byteCount(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	n, err := strconv.ParseInt(r.PathValue("n"), 10, 64)
+	if err != nil || n < 0 {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid byte count"})
+		return 0, false
+	}
+	if n > MaxGenerateBytes {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "byte count over the limit", Limit: MaxGenerateBytes})
+		return 0, false
+	}
+	return n, true
+}
+
+// randomSource returns random bytes, the same for every request with the
+// same ?seed=.
+func randomSource(w http.ResponseWriter, r *http.Request) (io.Reader, bool) {
+	var key [32]byte
+	if seed := r.URL.Query().Get("seed"); seed != "" {
+		n, err := strconv.ParseInt(seed, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid seed"})
+			return nil, false
+		}
+		binary.LittleEndian.PutUint64(key[:], uint64(n))
+	} else {
+		crand.Read(key[:])
+	}
+	return rand.NewChaCha8(key), true
+}
+
+// Bytes answers /bytes/{n} with n random bytes.
+func Bytes(w http.ResponseWriter, r *http.Request) {
+	n, ok := byteCount(w, r)
+	if !ok {
+		return
+	}
+	src, ok := randomSource(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(n, 10))
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.CopyN(w, src, n); err != nil {
+		log.Printf("Error writing bytes: %v", err)
+	}
+}
+
+// StreamBytes answers /stream-bytes/{n} with n random bytes, flushed in
+// chunks of ?chunk_size= without a Content-Length.
+func StreamBytes(w http.ResponseWriter, r *http.Request) {
+	n, ok := byteCount(w, r)
+	if !ok {
+		return
+	}
+	chunk := int64(DefaultChunkSize)
+	if s := r.URL.Query().Get("chunk_size"); s != "" {
+		size, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || size <= 0 || size > MaxGenerateBytes {
+			writeError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid chunk size", Limit: MaxGenerateBytes})
+			return
+		}
+		chunk = size
+	}
+	src, ok := randomSource(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if r.Method == http.MethodHead {
+		return
+	}
+	rc := http.NewResponseController(w)
+	for n > 0 {
+		size := min(chunk, n)
+		if _, err := io.CopyN(w, src, size); err != nil {
+			log.Printf("Error streaming bytes: %v", err)
+			return
+		}
+		rc.Flush()
+		n -= size
+	}
+}
+
+type UUIDResponse struct {
+	UUID string `json:"uuid"`
+}
+
+// UUID answers with a random version 4 UUID.
+func UUID(w http.ResponseWriter, r *http.Request) {
+	var b [16]byte
+	crand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	uuid := fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	render.Write(w, r, UUIDResponse{UUID: uuid})
+}
+
+// Base64 answers /base64/{value...} with the decoded value, which may use
+// the standard or URL alphabet, with or without padding.
+func Base64(w http.ResponseWriter, r *http.Request) {
+	value := r.PathValue("value")
+	if value == "" {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "missing base64 value"})
+		return
+	}
+	var decoded []byte
+	var err error
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if decoded, err = enc.DecodeString(value); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid base64"})
+		return
+	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(decoded))
+	if _, err := w.Write(decoded); err != nil {
+		log.Printf("Error writing decoded value: %v", err)
+	}
+}
+
+// Sample serves a fixed document, for clients that need something to parse.
+func Sample(contentType, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		if _, err := io.WriteString(w, body); err != nil {
+			log.Printf("Error writing sample: %v", err)
+		}
+	}
+}
+
+// Range answers /range/{n} with n bytes cycling through a to z, honouring
+// Range and If-Range. Several ranges get a multipart/byteranges body.
+func Range(w http.ResponseWriter, r *http.Request) {
+	n, ok := byteCount(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("ETag", fmt.Sprintf(`"range-%d"`, n))
+	http.ServeContent(w, r, "", time.Time{}, &letters{size: n})
+}
+
+// letters is a ReadSeeker over size bytes of the alphabet, repeated, so any
+// part of it can be served and checked without holding the whole.
+type letters struct {
+	size, off int64
+}
+
+func (l *letters) Read(p []byte) (int, error) {
+	if l.off >= l.size {
+		return 0, io.EOF
+	}
+	n := int(min(int64(len(p)), l.size-l.off))
+	for i := range n {
+		p[i] = 'a' + byte((l.off+int64(i))%26)
+	}
+	l.off += int64(n)
+	return n, nil
+}
+
+func (l *letters) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += l.off
+	case io.SeekEnd:
+		offset += l.size
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	l.off = offset
+	return offset, nil
+}
+
+const SampleJSON = `{
+  "slideshow": {
+    "author": "Yours Truly",
+    "date": "date of publication",
+    "title": "Sample Slide Show",
+    "slides": [
+      {
+        "title": "Wake up to WonderWidgets!",
+        "type": "all"
+      },
+      {
+        "title": "Overview",
+        "type": "all",
+        "items": [
+          "Why <em>WonderWidgets</em> are great",
+          "Who <em>buys</em> WonderWidgets"
+        ]
+      }
+    ]
+  }
+}
+`
+
+const SampleXML = `<?xml version="1.0" encoding="UTF-8"?>
+<slideshow title="Sample Slide Show" date="Date of publication" author="Yours Truly">
+  <slide type="all">
+    <title>Wake up to WonderWidgets!</title>
+  </slide>
+  <slide type="all">
+    <title>Overview</title>
+    <item>Why <em>WonderWidgets</em> are great</item>
+    <item/>
+    <item>Who <em>buys</em> WonderWidgets</item>
+  </slide>
+</slideshow>
+`
+
+const SampleHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>echobox sample</title>
+</head>
+<body>
+<h1>Moby-Dick</h1>
+<p>Call me Ishmael. Some years ago&mdash;never mind how long precisely&mdash;having little or no money in my purse, and nothing particular to interest me on shore, I thought I would sail about a little and see the watery part of the world.</p>
+</body>
+</html>
+`
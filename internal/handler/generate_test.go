@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func generate(h http.HandlerFunc, target, name, value string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.SetPathValue(name, value)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h(w, req)
+	return w
+}
+
+func TestBytes(t *testing.T) {
+	w := generate(Bytes, "/bytes/100?seed=42", "n", "100")
+	if w.Code != http.StatusOK || w.Body.Len() != 100 || w.Header().Get("Content-Length") != "100" {
+		t.Fatalf("Bytes() = %d with %d bytes, Content-Length %q, want 200 with 100", w.Code, w.Body.Len(), w.Header().Get("Content-Length"))
+	}
+
+	again := generate(Bytes, "/bytes/100?seed=42", "n", "100")
+	if again.Body.String() != w.Body.String() {
+		t.Error("Bytes() with the same seed differ, want them repeated")
+	}
+	other := generate(Bytes, "/bytes/100?seed=43", "n", "100")
+	unseeded := generate(Bytes, "/bytes/100", "n", "100")
+	if other.Body.String() == w.Body.String() || unseeded.Body.String() == w.Body.String() {
+		t.Error("Bytes() with another or no seed match, want them different")
+	}
+
+	for _, tt := range []struct{ target, n string }{
+		{"/bytes/x", "x"},
+		{"/bytes/-1", "-1"},
+		{"/bytes/999999999999", "999999999999"},
+		{"/bytes/1?seed=abc", "1"},
+	} {
+		if w := generate(Bytes, tt.target, "n", tt.n); w.Code != http.StatusBadRequest {
+			t.Errorf("Bytes(%s) status = %v, want %v", tt.target, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestStreamBytes(t *testing.T) {
+	w := generate(StreamBytes, "/stream-bytes/25?chunk_size=10&seed=1", "n", "25")
+	if w.Code != http.StatusOK || w.Body.Len() != 25 || !w.Flushed {
+		t.Errorf("StreamBytes() = %d with %d bytes, flushed %v, want 200 with 25 flushed", w.Code, w.Body.Len(), w.Flushed)
+	}
+	if w.Header().Get("Content-Length") != "" {
+		t.Errorf("StreamBytes() Content-Length = %q, want none", w.Header().Get("Content-Length"))
+	}
+	if seeded := generate(Bytes, "/bytes/25?seed=1", "n", "25"); seeded.Body.String() != w.Body.String() {
+		t.Error("StreamBytes() differs from Bytes() with the same seed, want the same bytes")
+	}
+
+	if w := generate(StreamBytes, "/stream-bytes/5?chunk_size=0", "n", "5"); w.Code != http.StatusBadRequest {
+		t.Errorf("StreamBytes(chunk_size=0) status = %v, want %v", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestStreamBytes_Head(t *testing.T) {
+	written := 0
+	counter := func(w http.ResponseWriter, r *http.Request) {
+		StreamBytes(writeCounter{w, &written}, r)
+	}
+	req := httptest.NewRequest(http.MethodHead, "/stream-bytes/1000", nil)
+	req.SetPathValue("n", "1000")
+	w := httptest.NewRecorder()
+	MethodAllow(StandardMethods, counter)(w, req)
+
+	if w.Code != http.StatusOK || w.Body.Len() != 0 || written != 0 {
+		t.Errorf("HEAD StreamBytes() = %d, %d bytes generated, want 200 without generating any", w.Code, written)
+	}
+}
+
+type writeCounter struct {
+	http.ResponseWriter
+	n *int
+}
+
+func (w writeCounter) Write(p []byte) (int, error) {
+	*w.n += len(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func TestUUID(t *testing.T) {
+	w := generate(UUID, "/uuid", "", "")
+	var resp UUIDResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(resp.UUID) {
+		t.Errorf("UUID() = %q, want a version 4 UUID", resp.UUID)
+	}
+}
+
+func TestBase64(t *testing.T) {
+	tests := []struct {
+		value      string
+		wantStatus int
+		wantBody   string
+	}{
+		{"aGVsbG8gd29ybGQ=", http.StatusOK, "hello world"},
+		{"aGVsbG8gd29ybGQ", http.StatusOK, "hello world"},
+		{"Pz8-", http.StatusOK, "??>"},
+		{"Pz8+", http.StatusOK, "??>"},
+		{"not base64!", http.StatusBadRequest, ""},
+		{"", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		w := generate(Base64, "/base64/x", "value", tt.value)
+		if w.Code != tt.wantStatus {
+			t.Errorf("Base64(%q) status = %v, want %v", tt.value, w.Code, tt.wantStatus)
+		}
+		if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+			t.Errorf("Base64(%q) = %q, want %q", tt.value, w.Body.String(), tt.wantBody)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(SampleJSON), &doc); err != nil {
+		t.Errorf("SampleJSON is not valid JSON: %v", err)
+	}
+
+	w := generate(Sample("application/xml", SampleXML), "/xml", "", "")
+	if w.Header().Get("Content-Type") != "application/xml" || w.Body.String() != SampleXML {
+		t.Errorf("Sample() = %q %q, want the document with its type", w.Header().Get("Content-Type"), w.Body.String())
+	}
+}
+
+func TestRange(t *testing.T) {
+	full := generate(Range, "/range/30", "n", "30")
+	if full.Code != http.StatusOK || full.Body.String() != "abcdefghijklmnopqrstuvwxyzabcd" {
+		t.Fatalf("Range() = %d %q, want the alphabet repeated", full.Code, full.Body.String())
+	}
+	if full.Header().Get("Accept-Ranges") != "bytes" || full.Header().Get("ETag") != `"range-30"` {
+		t.Errorf("Range() headers = %v, want Accept-Ranges and ETag", full.Header())
+	}
+
+	tests := []struct {
+		name       string
+		header     []string
+		wantStatus int
+		wantBody   string
+		wantRange  string
+	}{
+		{"single", []string{"Range", "bytes=2-4"}, http.StatusPartialContent, "cde", "bytes 2-4/30"},
+		{"suffix", []string{"Range", "bytes=-3"}, http.StatusPartialContent, "bcd", "bytes 27-29/30"},
+		{"if-range match", []string{"Range", "bytes=0-1", "If-Range", `"range-30"`}, http.StatusPartialContent, "ab", "bytes 0-1/30"},
+		{"if-range mismatch", []string{"Range", "bytes=0-1", "If-Range", `"other"`}, http.StatusOK, full.Body.String(), ""},
+		{"unsatisfiable", []string{"Range", "bytes=40-50"}, http.StatusRequestedRangeNotSatisfiable, "", "bytes */30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := generate(Range, "/range/30", "n", "30", tt.header...)
+			if w.Code != tt.wantStatus {
+				t.Errorf("Range() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("Range() body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if got := w.Header().Get("Content-Range"); got != tt.wantRange {
+				t.Errorf("Range() Content-Range = %q, want %q", got, tt.wantRange)
+			}
+		})
+	}
+
+	w := generate(Range, "/range/30", "n", "30", "Range", "bytes=0-1,26-27")
+	mediaType, params, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if w.Code != http.StatusPartialContent || mediaType != "multipart/byteranges" {
+		t.Fatalf("Range() = %d %s, want 206 multipart/byteranges", w.Code, mediaType)
+	}
+	mr := multipart.NewReader(w.Body, params["boundary"])
+	var parts []string
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := io.ReadAll(part)
+		parts = append(parts, part.Header.Get("Content-Range")+"="+string(body))
+	}
+	if want := "bytes 0-1/30=ab,bytes 26-27/30=ab"; strings.Join(parts, ",") != want {
+		t.Errorf("Range() parts = %v, want %s", parts, want)
+	}
+}
+
+func TestLetters_Seek(t *testing.T) {
+	l := &letters{size: 52}
+	if pos, err := l.Seek(-2, io.SeekEnd); err != nil || pos != 50 {
+		t.Fatalf("Seek(-2, end) = %d, %v, want 50", pos, err)
+	}
+	if b, _ := io.ReadAll(l); string(b) != "yz" {
+		t.Errorf("Read() after Seek = %q, want yz", b)
+	}
+	if _, err := l.Seek(-1, io.SeekStart); err == nil {
+		t.Error("Seek(-1) error = nil, want error")
+	}
+	if _, err := l.Seek(strconv.IntSize, io.SeekCurrent); err != nil {
+		t.Errorf("Seek() past the end error = %v, want nil", err)
+	}
+}
@@ -1,43 +1,97 @@
 package handler
 
 import (
+	"crypto/x509"
 	"encoding/json"
 	"io"
 	"log"
+	"maps"
 	"net/http"
+	"net/url"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+
+	"github.com/Elagoht/echobox/internal/metrics"
+	"github.com/Elagoht/echobox/internal/proxyproto"
+	"github.com/Elagoht/echobox/internal/render"
+	"github.com/Elagoht/echobox/internal/requestid"
+	"github.com/Elagoht/echobox/internal/tracing"
+	"github.com/Elagoht/echobox/internal/wire"
 )
 
 type EchoResponse struct {
-	Method  string              `json:"method"`
-	Path    string              `json:"path"`
-	Query   map[string][]string `json:"query"`
-	Headers map[string][]string `json:"headers"`
-	Body    string              `json:"body"`
+	Method    string              `json:"method"`
+	Proto     string              `json:"proto"`
+	Path      string              `json:"path"`
+	Query     map[string][]string `json:"query"`
+	Headers   map[string][]string `json:"headers"`
+	Body      string              `json:"body"`
+	BodyInfo  *BodyInfo           `json:"body_info,omitempty"`
+	RequestID string              `json:"request_id,omitempty"`
+	Trace     *tracing.Info       `json:"trace,omitempty"`
+	TLS       *TLSInfo            `json:"tls,omitempty"`
+	Proxy     *proxyproto.Header  `json:"proxy,omitempty"`
+}
+
+type EchoOptions struct {
+	// ClientCAs verifies client certificates presented over TLS.
+	ClientCAs *x509.CertPool
+	// BodyEchoLimit echoes only the first BodyEchoLimit bytes of larger
+	// bodies, with their size and digest in BodyInfo. 0 echoes everything.
+	BodyEchoLimit int64
 }
 
 func Echo(w http.ResponseWriter, r *http.Request) {
-	bodyBytes, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Printf("Error reading body: %v", err)
-		http.Error(w, "Error reading request body", http.StatusBadRequest)
-		return
+	NewEcho(EchoOptions{})(w, r)
+}
+
+func NewEcho(opts EchoOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bodyBytes, bodyInfo, err := readBody(r.Body, opts.BodyEchoLimit)
+		if err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		defer r.Body.Close()
+
+		resp := EchoResponse{
+			Method:    r.Method,
+			Proto:     r.Proto,
+			Path:      r.URL.Path,
+			Query:     r.URL.Query(),
+			Headers:   r.Header,
+			Body:      string(bodyBytes),
+			BodyInfo:  bodyInfo,
+			RequestID: requestid.FromContext(r.Context()),
+			Trace:     tracing.Parse(r.Header),
+			TLS:       newTLSInfo(r.TLS, opts.ClientCAs),
+			Proxy:     proxyproto.FromContext(r.Context()),
+		}
+
+		render.Write(w, r, resp)
 	}
-	defer r.Body.Close()
+}
 
-	resp := EchoResponse{
-		Method:  r.Method,
-		Path:    r.URL.Path,
-		Query:   r.URL.Query(),
-		Headers: r.Header,
-		Body:    string(bodyBytes),
+// WriteText writes the request as it would appear on the wire, for
+// ?format=text. Headers are sorted, as their original order is lost.
+func (e EchoResponse) WriteText(w io.Writer) error {
+	target := e.Path
+	if len(e.Query) > 0 {
+		target += "?" + url.Values(e.Query).Encode()
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		log.Printf("Error encoding response: %v", err)
+	var b strings.Builder
+	b.WriteString(e.Method + " " + target + " " + e.Proto + "\r\n")
+	for _, name := range slices.Sorted(maps.Keys(e.Headers)) {
+		for _, v := range e.Headers[name] {
+			b.WriteString(name + ": " + v + "\r\n")
+		}
 	}
+	b.WriteString("\r\n" + e.Body)
+	_, err := io.WriteString(w, b.String())
+	return err
 }
 
 func Headers(w http.ResponseWriter, r *http.Request) {
@@ -47,17 +101,58 @@ func Headers(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func Body(w http.ResponseWriter, r *http.Request) {
-	bodyBytes, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Printf("Error reading body: %v", err)
-		http.Error(w, "Error reading request body", http.StatusBadRequest)
+// Raw returns the request line and headers exactly as they arrived, which
+// needs an HTTP/1 request on a connection captured by the wire package.
+func Raw(w http.ResponseWriter, r *http.Request) {
+	head := wire.FromContext(r.Context())
+	if r.ProtoMajor != 1 || head == nil {
+		http.Error(w, "Raw capture needs HTTP/1 on a plain listener with RAW_CAPTURE enabled", http.StatusNotImplemented)
 		return
 	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := w.Write(head); err != nil {
+		log.Printf("Error writing raw request: %v", err)
+	}
+}
+
+// Body streams the request body back as it arrives, so its size is bounded
+// only by LimitBody, never by memory.
+func Body(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
+	metrics.Streams("body").Inc()
+	defer metrics.Streams("body").Dec()
+
+	// HTTP/1 servers stop reading the request once the response starts
+	// unless asked to run full duplex; HTTP/2 always does
+	rc := http.NewResponseController(w)
+	rc.EnableFullDuplex()
 
-	if _, err := w.Write(bodyBytes); err != nil {
-		log.Printf("Error writing body: %v", err)
+	buf := make([]byte, 32*1024)
+	for written := false; ; {
+		n, err := r.Body.Read(buf)
+		if err != nil && err != io.EOF && !written {
+			// Nothing sent yet, so an oversized or broken body still gets a status
+			writeBodyError(w, err)
+			return
+		}
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				log.Printf("Error writing body: %v", werr)
+				return
+			}
+			rc.Flush()
+			written = true
+		}
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			// The status is already sent; abort so the client sees a broken
+			// response rather than a short one
+			log.Printf("Error streaming body: %v", err)
+			panic(http.ErrAbortHandler)
+		}
 	}
 }
 
@@ -68,6 +163,21 @@ func Queries(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func CA(pem []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(pem) == 0 {
+			http.Error(w, "No generated CA available", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		w.Header().Set("Content-Disposition", `attachment; filename="ca.pem"`)
+		if _, err := w.Write(pem); err != nil {
+			log.Printf("Error writing CA: %v", err)
+		}
+	}
+}
+
 func MatchStatusCode(path string) bool {
 	matched, _ := regexp.MatchString("^/\\d{3}$", path)
 	if !matched {
@@ -91,9 +201,19 @@ func ServeStatusCode(w http.ResponseWriter, path string) {
 	}
 }
 
-func MethodAllow(h http.HandlerFunc) http.HandlerFunc {
+// Fixed answers every request with status, headers and body, for stubbing
+// endpoints echobox does not have. A status of 0 means 200.
+func Fixed(status int, headers map[string]string, body string) http.HandlerFunc {
+	if status == 0 {
+		status = http.StatusOK
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Allow", "GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS")
-		h(w, r)
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+		w.WriteHeader(status)
+		if _, err := io.WriteString(w, body); err != nil {
+			log.Printf("Error writing route body: %v", err)
+		}
 	}
 }
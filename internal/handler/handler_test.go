@@ -7,6 +7,8 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/Elagoht/echobox/internal/requestid"
 )
 
 type errorReader struct{}
@@ -259,7 +261,7 @@ func TestMethodAllow(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	wrapped := MethodAllow(testHandler)
+	wrapped := MethodAllow(StandardMethods, testHandler)
 
 	tests := []struct {
 		method string
@@ -386,3 +388,114 @@ func TestServeStatusCode_ErrorWriting(t *testing.T) {
 	_ = w.Code
 }
 
+
+func TestCA(t *testing.T) {
+	pem := []byte("-----BEGIN CERTIFICATE-----\ntest\n-----END CERTIFICATE-----\n")
+
+	w := httptest.NewRecorder()
+	CA(pem)(w, httptest.NewRequest(http.MethodGet, "/ca.pem", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("CA() status = %v, want %v", w.Code, http.StatusOK)
+	}
+	if w.Header().Get("Content-Type") != "application/x-pem-file" {
+		t.Errorf("CA() Content-Type = %v, want application/x-pem-file", w.Header().Get("Content-Type"))
+	}
+	if w.Body.String() != string(pem) {
+		t.Errorf("CA() body = %q, want %q", w.Body.String(), pem)
+	}
+}
+
+func TestCA_NotAvailable(t *testing.T) {
+	w := httptest.NewRecorder()
+	CA(nil)(w, httptest.NewRequest(http.MethodGet, "/ca.pem", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("CA() status = %v, want %v", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestEcho_Proto(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2.0", 2, 0
+	w := httptest.NewRecorder()
+
+	Echo(w, req)
+
+	var resp EchoResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Proto != "HTTP/2.0" {
+		t.Errorf("Echo() proto = %v, want HTTP/2.0", resp.Proto)
+	}
+	if resp.TLS != nil {
+		t.Errorf("Echo() tls = %+v, want nil for plain HTTP", resp.TLS)
+	}
+}
+
+func TestEcho_RequestIDAndTrace(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+
+	requestid.Middleware(http.HandlerFunc(Echo)).ServeHTTP(w, req)
+
+	var resp EchoResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.RequestID == "" || resp.RequestID != w.Header().Get("X-Request-Id") {
+		t.Errorf("Echo() request_id = %q, want the X-Request-Id response header %q", resp.RequestID, w.Header().Get("X-Request-Id"))
+	}
+	if resp.Trace == nil || resp.Trace.TraceParent == nil || resp.Trace.TraceParent.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Echo() trace = %+v, want the parsed traceparent", resp.Trace)
+	}
+
+	w = httptest.NewRecorder()
+	Echo(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Contains(w.Body.String(), `"trace"`) || strings.Contains(w.Body.String(), `"request_id"`) {
+		t.Errorf("Echo() = %s, want no trace or request_id without them", w.Body.String())
+	}
+}
+
+func TestEcho_Formats(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/path?b=2&a=1&format=text", strings.NewReader("hello"))
+	req.Header.Set("X-Two", "2")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	Echo(w, req)
+
+	want := "POST /path?a=1&b=2&format=text HTTP/1.1\r\nAccept: application/json\r\nX-Two: 2\r\n\r\nhello"
+	if w.Body.String() != want {
+		t.Errorf("Echo() text = %q, want %q", w.Body.String(), want)
+	}
+	if w.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Errorf("Echo() Content-Type = %v, want text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/yaml")
+	w = httptest.NewRecorder()
+	Echo(w, req)
+	if !strings.HasPrefix(w.Body.String(), "method: GET\n") {
+		t.Errorf("Echo() yaml = %q, want the echo as YAML", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "image/png")
+	w = httptest.NewRecorder()
+	Echo(w, req)
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("Echo() status = %v, want %v", w.Code, http.StatusNotAcceptable)
+	}
+}
+
+func TestRaw_Uncaptured(t *testing.T) {
+	w := httptest.NewRecorder()
+	Raw(w, httptest.NewRequest(http.MethodGet, "/raw", nil))
+
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Raw() status = %v, want %v", w.Code, http.StatusNotImplemented)
+	}
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Elagoht/echobox/internal/render"
+)
+
+const (
+	DefaultImageSize = 256
+	MaxImageSize     = 2048
+)
+
+// DefaultImageColor fills images without ?color=.
+var DefaultImageColor = color.RGBA{R: 0x3b, G: 0x82, B: 0xf6, A: 0xff}
+
+// imageTypes maps the formats /image can send to their media types, in the
+// order /image prefers them.
+var imageTypes = []struct{ format, mediaType string }{
+	{"png", "image/png"},
+	{"jpeg", "image/jpeg"},
+	{"gif", "image/gif"},
+	{"svg", "image/svg+xml"},
+}
+
+// Image answers with a width by height gradient of ?color= in format, or in
+// whichever format Accept prefers when format is empty.
+func Image(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f := format
+		if f == "" {
+			w.Header().Add("Vary", "Accept")
+			var offers []string
+			for _, t := range imageTypes {
+				offers = append(offers, t.mediaType)
+			}
+			mediaType, ok := render.Match(r, offers)
+			if !ok {
+				http.Error(w, "Not acceptable, supported types: "+strings.Join(offers, ", "), http.StatusNotAcceptable)
+				return
+			}
+			for _, t := range imageTypes {
+				if t.mediaType == mediaType {
+					f = t.format
+				}
+			}
+		}
+
+		width, height, c, ok := imageParams(w, r)
+		if !ok {
+			return
+		}
+
+		var buf bytes.Buffer
+		var err error
+		switch f {
+		case "svg":
+			writeSVG(&buf, width, height, c)
+		case "png":
+			err = png.Encode(&buf, gradient(width, height, c))
+		case "jpeg":
+			err = jpeg.Encode(&buf, gradient(width, height, c), &jpeg.Options{Quality: 90})
+		case "gif":
+			err = gif.Encode(&buf, gradient(width, height, c), nil)
+		}
+		if err != nil {
+			log.Printf("Error encoding image: %v", err)
+			http.Error(w, "Error encoding image", http.StatusInternalServerError)
+			return
+		}
+
+		for _, t := range imageTypes {
+			if t.format == f {
+				w.Header().Set("Content-Type", t.mediaType)
+			}
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			log.Printf("Error writing image: %v", err)
+		}
+	}
+}
+
+// imageParams reads ?width=, ?height= and ?color=, answering 400 when one
+// is invalid.
+func imageParams(w http.ResponseWriter, r *http.Request) (width, height int, c color.RGBA, ok bool) {
+	q := r.URL.Query()
+	width, height, c = DefaultImageSize, DefaultImageSize, DefaultImageColor
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"width", &width}, {"height", &height}} {
+		s := q.Get(p.name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > MaxImageSize {
+			writeError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid " + p.name, Limit: MaxImageSize})
+			return 0, 0, c, false
+		}
+		*p.dst = n
+	}
+	if s := q.Get("color"); s != "" {
+		var err error
+		if c, err = parseColor(s); err != nil {
+			writeError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return 0, 0, c, false
+		}
+	}
+	return width, height, c, true
+}
+
+// parseColor reads a hex color such as ff8800, #ff8800 or #f80.
+func parseColor(s string) (color.RGBA, error) {
+	digits := strings.TrimPrefix(s, "#")
+	if len(digits) == 3 {
+		digits = string([]byte{digits[0], digits[0], digits[1], digits[1], digits[2], digits[2]})
+	}
+	b, err := hex.DecodeString(digits)
+	if err != nil || len(b) != 3 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, want hex such as ff8800", s)
+	}
+	return color.RGBA{R: b[0], G: b[1], B: b[2], A: 0xff}, nil
+}
+
+// gradient shades c from full strength at the top to half at the bottom, so
+// the image is not a single flat color that encoders reduce to nothing.
+func gradient(width, height int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		shade := shadeAt(c, y, height)
+		for x := range width {
+			img.SetRGBA(x, y, shade)
+		}
+	}
+	return img
+}
+
+func shadeAt(c color.RGBA, y, height int) color.RGBA {
+	scale := func(v uint8) uint8 {
+		return uint8(int(v) * (2*height - y) / (2 * height))
+	}
+	return color.RGBA{R: scale(c.R), G: scale(c.G), B: scale(c.B), A: 0xff}
+}
+
+func writeSVG(buf *bytes.Buffer, width, height int, c color.RGBA) {
+	dark := shadeAt(c, height, height)
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">
+<defs><linearGradient id="g" x1="0" y1="0" x2="0" y2="1"><stop offset="0" stop-color="#%02x%02x%02x"/><stop offset="1" stop-color="#%02x%02x%02x"/></linearGradient></defs>
+<rect width="100%%" height="100%%" fill="url(#g)"/>
+</svg>
+`, width, height, width, height, c.R, c.G, c.B, dark.R, dark.G, dark.B)
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestImage(t *testing.T) {
+	tests := []struct {
+		format     string
+		accept     string
+		wantType   string
+		wantFormat string
+	}{
+		{"png", "", "image/png", "png"},
+		{"jpeg", "", "image/jpeg", "jpeg"},
+		{"gif", "", "image/gif", "gif"},
+		{"", "", "image/png", "png"},
+		{"", "image/webp, image/gif;q=0.9, image/*;q=0.5", "image/gif", "gif"},
+		{"", "image/jpeg", "image/jpeg", "jpeg"},
+		{"png", "image/jpeg", "image/png", "png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format+" "+tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/image?width=40&height=20&color=ff0000", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			Image(tt.format)(w, req)
+
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != tt.wantType {
+				t.Fatalf("Image() = %d %q, want 200 %s", w.Code, w.Header().Get("Content-Type"), tt.wantType)
+			}
+			img, format, err := image.Decode(bytes.NewReader(w.Body.Bytes()))
+			if err != nil || format != tt.wantFormat {
+				t.Fatalf("image.Decode() = %s, %v, want %s", format, err, tt.wantFormat)
+			}
+			if b := img.Bounds(); b.Dx() != 40 || b.Dy() != 20 {
+				t.Errorf("Image() size = %dx%d, want 40x20", b.Dx(), b.Dy())
+			}
+			if r, g, b, _ := img.At(20, 0).RGBA(); r>>8 < 0xe0 || g>>8 > 0x20 || b>>8 > 0x20 {
+				t.Errorf("Image() top color = %d,%d,%d, want red", r>>8, g>>8, b>>8)
+			}
+		})
+	}
+}
+
+func TestImage_SVG(t *testing.T) {
+	w := httptest.NewRecorder()
+	Image("svg")(w, httptest.NewRequest(http.MethodGet, "/image/svg?width=30&color=%23f80", nil))
+
+	if w.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Errorf("Image() Content-Type = %q, want image/svg+xml", w.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{`width="30" height="256"`, `stop-color="#ff8800"`} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("Image() = %s, want %s", w.Body.String(), want)
+		}
+	}
+}
+
+func TestImage_Errors(t *testing.T) {
+	tests := []struct {
+		target     string
+		accept     string
+		wantStatus int
+	}{
+		{"/image", "image/webp", http.StatusNotAcceptable},
+		{"/image?width=0", "", http.StatusBadRequest},
+		{"/image?height=99999", "", http.StatusBadRequest},
+		{"/image?color=blue", "", http.StatusBadRequest},
+		{"/image?color=ff00", "", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		req.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		Image("")(w, req)
+		if w.Code != tt.wantStatus {
+			t.Errorf("Image(%s, %q) status = %v, want %v", tt.target, tt.accept, w.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	for input, want := range map[string]color.RGBA{
+		"ff8800":  {R: 0xff, G: 0x88, A: 0xff},
+		"#FF8800": {R: 0xff, G: 0x88, A: 0xff},
+		"#f80":    {R: 0xff, G: 0x88, A: 0xff},
+	} {
+		if got, err := parseColor(input); err != nil || got != want {
+			t.Errorf("parseColor(%q) = %v, %v, want %v", input, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// StandardMethods are accepted by the echo and the endpoints built on it.
+var StandardMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodHead,
+	http.MethodOptions,
+}
+
+// MethodAllow advertises methods in the Allow header and answers any other
+// method with 405. HEAD requests run h with the body discarded, so they get
+// the headers and Content-Length a GET would.
+func MethodAllow(methods []string, h http.HandlerFunc) http.HandlerFunc {
+	allow := strings.Join(methods, ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		if !slices.Contains(methods, r.Method) {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Method != http.MethodHead {
+			h(w, r)
+			return
+		}
+
+		hw := &headWriter{ResponseWriter: w}
+		h(hw, r)
+		hw.finish()
+	}
+}
+
+// headWriter holds back the status until the handler returns, counting the
+// body instead of sending it unless the handler declared Content-Length.
+type headWriter struct {
+	http.ResponseWriter
+	status int
+	n      int
+	sent   bool
+}
+
+func (w *headWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.Header()
+	if _, ok := h["Content-Type"]; !ok && w.n == 0 && len(p) > 0 {
+		// net/http would sniff the body a GET sends
+		h.Set("Content-Type", http.DetectContentType(p))
+	}
+	if _, ok := h["Content-Length"]; !ok {
+		w.n += len(p)
+	}
+	return len(p), nil
+}
+
+// FlushError sends the headers as they stand, since a GET would have sent
+// them by now, without a Content-Length the body has not reached yet.
+func (w *headWriter) FlushError() error {
+	w.send(false)
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the connection's deadlines.
+func (w *headWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *headWriter) finish() {
+	w.send(true)
+}
+
+func (w *headWriter) send(complete bool) {
+	if w.sent {
+		return
+	}
+	w.sent = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.Header()
+	if _, ok := h["Content-Length"]; !ok && complete && w.n > 0 {
+		h.Set("Content-Length", strconv.Itoa(w.n))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMethodAllow_Rejects(t *testing.T) {
+	called := false
+	h := MethodAllow([]string{http.MethodPost}, func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	for _, method := range []string{http.MethodGet, http.MethodHead, "PROPFIND"} {
+		called = false
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(method, "/post", nil))
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s status = %d, want %d", method, w.Code, http.StatusMethodNotAllowed)
+		}
+		if got := w.Header().Get("Allow"); got != "POST" {
+			t.Errorf("%s Allow = %q, want POST", method, got)
+		}
+		if called {
+			t.Errorf("%s reached the handler, want it rejected", method)
+		}
+	}
+}
+
+func TestMethodAllow_Head(t *testing.T) {
+	tests := []struct {
+		name       string
+		h          http.HandlerFunc
+		wantStatus int
+		wantLength string
+		wantType   string
+	}{
+		{
+			name: "body",
+			h: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"a":`))
+				w.Write([]byte(`1}`))
+			},
+			wantStatus: http.StatusOK,
+			wantLength: "7",
+			wantType:   "application/json",
+		},
+		{
+			name: "sniffed",
+			h: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+				w.Write([]byte("short and stout"))
+			},
+			wantStatus: http.StatusTeapot,
+			wantLength: "15",
+			wantType:   "text/plain; charset=utf-8",
+		},
+		{
+			name: "declared length",
+			h: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "100")
+				w.Write([]byte("x"))
+			},
+			wantStatus: http.StatusOK,
+			wantLength: "100",
+			wantType:   "text/plain; charset=utf-8",
+		},
+		{
+			name:       "empty",
+			h:          func(w http.ResponseWriter, r *http.Request) {},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			MethodAllow(StandardMethods, tt.h)(w, httptest.NewRequest(http.MethodHead, "/", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("HEAD status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if w.Body.Len() != 0 {
+				t.Errorf("HEAD body = %q, want none", w.Body.String())
+			}
+			if got := w.Header().Get("Content-Length"); got != tt.wantLength {
+				t.Errorf("HEAD Content-Length = %q, want %q", got, tt.wantLength)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("HEAD Content-Type = %q, want %q", got, tt.wantType)
+			}
+		})
+	}
+}
+
+// deadlineRecorder is a ResponseRecorder whose write deadline can be set
+// through http.ResponseController, as on a real connection.
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	deadline time.Time
+}
+
+func (w *deadlineRecorder) SetWriteDeadline(t time.Time) error {
+	w.deadline = t
+	return nil
+}
+
+func TestMethodAllow_HeadController(t *testing.T) {
+	deadline := time.Now().Add(time.Minute)
+	w := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+	MethodAllow(StandardMethods, func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(deadline); err != nil {
+			t.Errorf("SetWriteDeadline() error = %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("partial"))
+		if err := rc.Flush(); err != nil {
+			t.Errorf("Flush() error = %v", err)
+		}
+		w.Write([]byte("rest"))
+	})(w, httptest.NewRequest(http.MethodHead, "/", nil))
+
+	if !w.deadline.Equal(deadline) {
+		t.Errorf("HEAD write deadline = %v, want %v", w.deadline, deadline)
+	}
+	if w.Code != http.StatusAccepted || !w.Flushed || w.Body.Len() != 0 {
+		t.Errorf("HEAD = %d, flushed %v, %d bytes, want 202 flushed without a body", w.Code, w.Flushed, w.Body.Len())
+	}
+	if got := w.Header().Get("Content-Length"); got != "" {
+		t.Errorf("HEAD Content-Length = %q, want none once flushed early", got)
+	}
+}
+
+func TestHeadWriter_DeclaredLength(t *testing.T) {
+	hw := &headWriter{ResponseWriter: httptest.NewRecorder()}
+	hw.Header().Set("Content-Length", "10")
+	hw.Write(make([]byte, 10))
+
+	if hw.n != 0 {
+		t.Errorf("headWriter counted %d bytes, want none once Content-Length is declared", hw.n)
+	}
+}
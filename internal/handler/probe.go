@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Elagoht/echobox/internal/health"
+	"github.com/Elagoht/echobox/internal/version"
+)
+
+type ProbeResponse struct {
+	Status string `json:"status"`
+}
+
+// Health answers as the admin API last set it, healthy by default.
+func Health(state *health.State) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result := state.Check(time.Now())
+		if result.Delay > 0 {
+			select {
+			case <-time.After(result.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		status := http.StatusOK
+		if !result.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+		writeProbe(w, status, result.Status)
+	}
+}
+
+// Ready answers 503 once the server starts draining, so load balancers stop
+// sending it new traffic before it closes its listeners.
+func Ready(state *health.State) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !state.Ready() {
+			writeProbe(w, http.StatusServiceUnavailable, "draining")
+			return
+		}
+		writeProbe(w, http.StatusOK, "ready")
+	}
+}
+
+func Version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(version.Get()); err != nil {
+		log.Printf("Error encoding version: %v", err)
+	}
+}
+
+func writeProbe(w http.ResponseWriter, status int, s string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(ProbeResponse{Status: s}); err != nil {
+		log.Printf("Error encoding probe: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"time"
+)
+
+type TLSInfo struct {
+	Version            string            `json:"version"`
+	CipherSuite        string            `json:"cipher_suite"`
+	ServerName         string            `json:"server_name,omitempty"`
+	NegotiatedProtocol string            `json:"alpn,omitempty"`
+	ClientCertificates []CertificateInfo `json:"client_certificates,omitempty"`
+	ClientVerified     bool              `json:"client_verified"`
+	ClientVerifyError  string            `json:"client_verify_error,omitempty"`
+}
+
+type CertificateInfo struct {
+	Subject           string    `json:"subject"`
+	Issuer            string    `json:"issuer"`
+	SerialNumber      string    `json:"serial_number"`
+	NotBefore         time.Time `json:"not_before"`
+	NotAfter          time.Time `json:"not_after"`
+	DNSNames          []string  `json:"dns_names,omitempty"`
+	EmailAddresses    []string  `json:"email_addresses,omitempty"`
+	IPAddresses       []string  `json:"ip_addresses,omitempty"`
+	URIs              []string  `json:"uris,omitempty"`
+	SHA256Fingerprint string    `json:"sha256_fingerprint"`
+}
+
+// newTLSInfo describes the connection and the client certificate chain it
+// presented. Chains verified during the handshake are trusted as is;
+// otherwise the chain is checked against clientCAs so the result can be
+// reported even when the handshake did not require verification.
+func newTLSInfo(state *tls.ConnectionState, clientCAs *x509.CertPool) *TLSInfo {
+	if state == nil {
+		return nil
+	}
+
+	info := &TLSInfo{
+		Version:            tls.VersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		ServerName:         state.ServerName,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+	}
+
+	for _, cert := range state.PeerCertificates {
+		info.ClientCertificates = append(info.ClientCertificates, newCertificateInfo(cert))
+	}
+
+	switch {
+	case len(state.PeerCertificates) == 0:
+		info.ClientVerifyError = "no client certificate presented"
+	case len(state.VerifiedChains) > 0:
+		info.ClientVerified = true
+	case clientCAs == nil:
+		info.ClientVerifyError = "no client CA configured"
+	default:
+		intermediates := x509.NewCertPool()
+		for _, cert := range state.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+			Roots:         clientCAs,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		if err != nil {
+			info.ClientVerifyError = err.Error()
+		} else {
+			info.ClientVerified = true
+		}
+	}
+
+	return info
+}
+
+func newCertificateInfo(cert *x509.Certificate) CertificateInfo {
+	fingerprint := sha256.Sum256(cert.Raw)
+	info := CertificateInfo{
+		Subject:           cert.Subject.String(),
+		Issuer:            cert.Issuer.String(),
+		SerialNumber:      hex.EncodeToString(cert.SerialNumber.Bytes()),
+		NotBefore:         cert.NotBefore,
+		NotAfter:          cert.NotAfter,
+		DNSNames:          cert.DNSNames,
+		EmailAddresses:    cert.EmailAddresses,
+		SHA256Fingerprint: hex.EncodeToString(fingerprint[:]),
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		info.URIs = append(info.URIs, uri.String())
+	}
+	return info
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Elagoht/echobox/internal/certs"
+)
+
+func clientState(t *testing.T, ca *certs.Authority) *tls.ConnectionState {
+	t.Helper()
+
+	cert, err := ca.IssueClient("client-a")
+	if err != nil {
+		t.Fatalf("IssueClient() error = %v", err)
+	}
+	return &tls.ConnectionState{
+		Version:          tls.VersionTLS13,
+		CipherSuite:      tls.TLS_AES_128_GCM_SHA256,
+		ServerName:       "localhost",
+		PeerCertificates: []*x509.Certificate{cert.Leaf, ca.Cert},
+	}
+}
+
+func TestNewTLSInfo(t *testing.T) {
+	ca, err := certs.NewAuthority()
+	if err != nil {
+		t.Fatalf("NewAuthority() error = %v", err)
+	}
+	other, err := certs.NewAuthority()
+	if err != nil {
+		t.Fatalf("NewAuthority() error = %v", err)
+	}
+
+	trusted := x509.NewCertPool()
+	trusted.AddCert(ca.Cert)
+	untrusted := x509.NewCertPool()
+	untrusted.AddCert(other.Cert)
+
+	tests := []struct {
+		name         string
+		state        *tls.ConnectionState
+		pool         *x509.CertPool
+		wantCerts    int
+		wantVerified bool
+		wantError    bool
+	}{
+		{"no client certificate", &tls.ConnectionState{Version: tls.VersionTLS12}, trusted, 0, false, true},
+		{"verified against pool", clientState(t, ca), trusted, 2, true, false},
+		{"untrusted issuer", clientState(t, ca), untrusted, 2, false, true},
+		{"no pool configured", clientState(t, ca), nil, 2, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := newTLSInfo(tt.state, tt.pool)
+
+			if len(info.ClientCertificates) != tt.wantCerts {
+				t.Errorf("newTLSInfo() certificates = %d, want %d", len(info.ClientCertificates), tt.wantCerts)
+			}
+			if info.ClientVerified != tt.wantVerified {
+				t.Errorf("newTLSInfo() verified = %v, want %v", info.ClientVerified, tt.wantVerified)
+			}
+			if (info.ClientVerifyError != "") != tt.wantError {
+				t.Errorf("newTLSInfo() verify error = %q, wantError %v", info.ClientVerifyError, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestNewTLSInfo_Details(t *testing.T) {
+	ca, err := certs.NewAuthority()
+	if err != nil {
+		t.Fatalf("NewAuthority() error = %v", err)
+	}
+
+	info := newTLSInfo(clientState(t, ca), nil)
+
+	if info.Version != "TLS 1.3" || info.CipherSuite != "TLS_AES_128_GCM_SHA256" || info.ServerName != "localhost" {
+		t.Errorf("newTLSInfo() = %+v, want TLS 1.3 details", info)
+	}
+
+	leaf := info.ClientCertificates[0]
+	if leaf.Subject != "CN=client-a,O=Echobox" {
+		t.Errorf("newTLSInfo() subject = %v, want CN=client-a,O=Echobox", leaf.Subject)
+	}
+	if leaf.Issuer != "CN=Echobox Test CA,O=Echobox" {
+		t.Errorf("newTLSInfo() issuer = %v, want CN=Echobox Test CA,O=Echobox", leaf.Issuer)
+	}
+	if len(leaf.SHA256Fingerprint) != 64 || leaf.SerialNumber == "" {
+		t.Errorf("newTLSInfo() fingerprint = %q serial = %q, want both set", leaf.SHA256Fingerprint, leaf.SerialNumber)
+	}
+
+	if newTLSInfo(nil, nil) != nil {
+		t.Error("newTLSInfo(nil) should be nil for plain HTTP")
+	}
+}
+
+func TestNewEcho_ClientCertificate(t *testing.T) {
+	ca, err := certs.NewAuthority()
+	if err != nil {
+		t.Fatalf("NewAuthority() error = %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.TLS = clientState(t, ca)
+	w := httptest.NewRecorder()
+
+	NewEcho(EchoOptions{ClientCAs: pool})(w, req)
+
+	var resp EchoResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.TLS == nil || !resp.TLS.ClientVerified {
+		t.Errorf("NewEcho() tls = %+v, want verified client certificate", resp.TLS)
+	}
+}
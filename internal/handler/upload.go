@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/Elagoht/echobox/internal/render"
+)
+
+// UploadOptions bounds what /upload accepts. Zero values are unlimited.
+type UploadOptions struct {
+	// MaxPartBytes rejects uploads with a part larger than MaxPartBytes.
+	MaxPartBytes int64
+	// MaxParts rejects uploads with more than MaxParts parts.
+	MaxParts int
+}
+
+type UploadResponse struct {
+	Parts []PartInfo `json:"parts"`
+	Size  int64      `json:"size"`
+}
+
+// PartInfo describes one part of a multipart upload without its content.
+type PartInfo struct {
+	Headers      map[string][]string `json:"headers"`
+	Name         string              `json:"name"`
+	Filename     string              `json:"filename,omitempty"`
+	ContentType  string              `json:"content_type,omitempty"`
+	DetectedType string              `json:"detected_type"`
+	Size         int64               `json:"size"`
+	SHA256       string              `json:"sha256"`
+	MD5          string              `json:"md5"`
+}
+
+// errPartTooLarge stops reading a part once it goes over MaxPartBytes.
+var errPartTooLarge = errors.New("part too large")
+
+// NewUpload answers multipart uploads with a description of every part. Parts
+// are hashed as they stream in, so only their first 512 bytes, used to detect
+// their type, are held at once.
+func NewUpload(opts UploadOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			writeError(w, http.StatusUnsupportedMediaType, ErrorResponse{Error: "expected a multipart body: " + err.Error()})
+			return
+		}
+
+		resp := UploadResponse{Parts: []PartInfo{}}
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				writeUploadError(w, err)
+				return
+			}
+			if opts.MaxParts > 0 && len(resp.Parts) == opts.MaxParts {
+				writeError(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: "too many parts", Limit: int64(opts.MaxParts)})
+				return
+			}
+
+			info, err := readPart(part, opts.MaxPartBytes)
+			if errors.Is(err, errPartTooLarge) {
+				writeError(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf("part %d too large", len(resp.Parts)), Limit: opts.MaxPartBytes})
+				return
+			}
+			if err != nil {
+				writeUploadError(w, err)
+				return
+			}
+			resp.Parts = append(resp.Parts, info)
+			resp.Size += info.Size
+		}
+
+		render.Write(w, r, resp)
+	}
+}
+
+// readPart streams part through its digests, failing with errPartTooLarge
+// once it goes over a positive maxBytes.
+func readPart(part *multipart.Part, maxBytes int64) (PartInfo, error) {
+	defer part.Close()
+
+	var sniff [512]byte
+	n, err := io.ReadFull(part, sniff[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return PartInfo{}, err
+	}
+
+	sha, md := sha256.New(), md5.New()
+	hashes := io.MultiWriter(sha, md)
+	hashes.Write(sniff[:n])
+
+	var src io.Reader = part
+	if maxBytes > 0 {
+		// One byte over the limit is enough to tell it was exceeded
+		src = io.LimitReader(part, max(0, maxBytes-int64(n))+1)
+	}
+	rest, err := io.Copy(hashes, src)
+	if err != nil {
+		return PartInfo{}, err
+	}
+	size := int64(n) + rest
+	if maxBytes > 0 && size > maxBytes {
+		return PartInfo{}, errPartTooLarge
+	}
+
+	return PartInfo{
+		Headers:      part.Header,
+		Name:         part.FormName(),
+		Filename:     part.FileName(),
+		ContentType:  part.Header.Get("Content-Type"),
+		DetectedType: http.DetectContentType(sniff[:n]),
+		Size:         size,
+		SHA256:       hex.EncodeToString(sha.Sum(nil)),
+		MD5:          hex.EncodeToString(md.Sum(nil)),
+	}, nil
+}
+
+// writeUploadError answers a body that went over LimitBody with 413 and a
+// malformed one with 400.
+func writeUploadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeBodyError(w, err)
+		return
+	}
+	writeError(w, http.StatusBadRequest, ErrorResponse{Error: "invalid multipart body: " + err.Error()})
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// multipartBody encodes fields as parts named after their keys; a name with
+// a slash is sent as a file called after what follows it.
+func multipartBody(t *testing.T, fields ...[2]string) (*bytes.Buffer, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, f := range fields {
+		name, filename, isFile := strings.Cut(f[0], "/")
+		header := textproto.MIMEHeader{}
+		if isFile {
+			header.Set("Content-Disposition", `form-data; name="`+name+`"; filename="`+filename+`"`)
+			header.Set("Content-Type", "application/octet-stream")
+		} else {
+			header.Set("Content-Disposition", `form-data; name="`+name+`"`)
+		}
+		pw, err := mw.CreatePart(header)
+		if err != nil {
+			t.Fatalf("CreatePart() error = %v", err)
+		}
+		pw.Write([]byte(f[1]))
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return &buf, mw.FormDataContentType()
+}
+
+func upload(t *testing.T, opts UploadOptions, fields ...[2]string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, contentType := multipartBody(t, fields...)
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	NewUpload(opts)(w, req)
+	return w
+}
+
+func TestUpload(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 2000)
+	w := upload(t, UploadOptions{}, [2]string{"note", "hello"}, [2]string{"file/a.png", png})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Upload() status = %v, want 200: %s", w.Code, w.Body.String())
+	}
+
+	var resp UploadResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode upload: %v", err)
+	}
+	if len(resp.Parts) != 2 || resp.Size != int64(5+len(png)) {
+		t.Fatalf("Upload() = %+v, want 2 parts of %d bytes", resp, 5+len(png))
+	}
+
+	sha := sha256.Sum256([]byte(png))
+	md := md5.Sum([]byte(png))
+	want := PartInfo{
+		Name:         "file",
+		Filename:     "a.png",
+		ContentType:  "application/octet-stream",
+		DetectedType: "image/png",
+		Size:         int64(len(png)),
+		SHA256:       hex.EncodeToString(sha[:]),
+		MD5:          hex.EncodeToString(md[:]),
+	}
+	got := resp.Parts[1]
+	if got.Headers["Content-Disposition"] == nil {
+		t.Errorf("Upload() part headers = %v, want Content-Disposition", got.Headers)
+	}
+	got.Headers = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Upload() part = %+v, want %+v", got, want)
+	}
+
+	note := resp.Parts[0]
+	if note.Name != "note" || note.Filename != "" || note.Size != 5 || note.DetectedType != "text/plain; charset=utf-8" {
+		t.Errorf("Upload() field = %+v, want a 5 byte text field", note)
+	}
+}
+
+func TestUpload_Limits(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       UploadOptions
+		wantStatus int
+	}{
+		{"unlimited", UploadOptions{}, http.StatusOK},
+		{"at part limit", UploadOptions{MaxPartBytes: 600}, http.StatusOK},
+		{"over part limit", UploadOptions{MaxPartBytes: 599}, http.StatusRequestEntityTooLarge},
+		{"under sniffed bytes", UploadOptions{MaxPartBytes: 10}, http.StatusRequestEntityTooLarge},
+		{"at part count", UploadOptions{MaxParts: 2}, http.StatusOK},
+		{"over part count", UploadOptions{MaxParts: 1}, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := upload(t, tt.opts, [2]string{"a", "small"}, [2]string{"b/b.bin", strings.Repeat("b", 600)})
+			if w.Code != tt.wantStatus {
+				t.Errorf("Upload() status = %v, want %v: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUpload_Invalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{"not multipart", "application/json", "{}", http.StatusUnsupportedMediaType},
+		{"no boundary", "multipart/form-data", "", http.StatusUnsupportedMediaType},
+		{"truncated", "multipart/form-data; boundary=x", "--x\r\nContent-Disposition: form-data; name=\"a\"\r\n\r\nabc", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			NewUpload(UploadOptions{})(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Upload() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Elagoht/echobox/internal/metrics"
+	"github.com/Elagoht/echobox/internal/websocket"
+)
+
+type WebSocketInfo struct {
+	Path        string              `json:"path"`
+	Query       map[string][]string `json:"query"`
+	Headers     map[string][]string `json:"headers"`
+	Subprotocol string              `json:"subprotocol"`
+	Compressed  bool                `json:"compressed"`
+}
+
+type webSocketOptions struct {
+	upgrade   websocket.UpgradeOptions
+	info      bool
+	closeCode int
+	limit     int
+}
+
+// WebSocket echoes every text and binary message back to the client.
+// Query parameters tune the session:
+//
+//	protocols  comma-separated subprotocols the server agrees to speak
+//	deflate    "false" disables permessage-deflate negotiation
+//	info       "true" sends the upgrade request as a JSON text message first
+//	close      close code the server answers with (default mirrors the client)
+//	limit      number of messages to echo before the server closes
+func WebSocket(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseWebSocketOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r, opts.upgrade)
+	if err != nil {
+		log.Printf("WebSocket handshake failed: %v", err)
+		return
+	}
+	defer conn.Close()
+	metrics.Streams("websocket").Inc()
+	defer metrics.Streams("websocket").Dec()
+
+	if opts.info {
+		info, err := json.Marshal(WebSocketInfo{
+			Path:        r.URL.Path,
+			Query:       r.URL.Query(),
+			Headers:     r.Header,
+			Subprotocol: conn.Subprotocol,
+			Compressed:  conn.Compressed(),
+		})
+		if err != nil {
+			log.Printf("Error encoding WebSocket info: %v", err)
+			return
+		}
+		if err := conn.WriteMessage(websocket.OpText, info); err != nil {
+			log.Printf("Error writing WebSocket info: %v", err)
+			return
+		}
+	}
+
+	echoed := 0
+	for {
+		op, data, err := conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				conn.WriteClose(closeErr.Code, closeErr.Reason)
+			} else if !errors.Is(err, io.EOF) {
+				log.Printf("Error reading WebSocket message: %v", err)
+			}
+			return
+		}
+
+		switch op {
+		case websocket.OpText, websocket.OpBinary:
+			if err := conn.WriteMessage(op, data); err != nil {
+				log.Printf("Error writing WebSocket message: %v", err)
+				return
+			}
+			echoed++
+			if opts.limit > 0 && echoed >= opts.limit {
+				code := opts.closeCode
+				if code == 0 {
+					code = websocket.CloseNormal
+				}
+				conn.WriteClose(code, "message limit reached")
+				return
+			}
+		case websocket.OpPing:
+			if err := conn.WriteMessage(websocket.OpPong, data); err != nil {
+				log.Printf("Error writing WebSocket pong: %v", err)
+				return
+			}
+		case websocket.OpClose:
+			code, reason, err := websocket.ParseClose(data)
+			if err != nil {
+				var closeErr *websocket.CloseError
+				errors.As(err, &closeErr)
+				conn.WriteClose(closeErr.Code, closeErr.Reason)
+				return
+			}
+			if opts.closeCode != 0 {
+				code = opts.closeCode
+			}
+			conn.WriteClose(code, reason)
+			return
+		}
+	}
+}
+
+func parseWebSocketOptions(r *http.Request) (webSocketOptions, error) {
+	query := r.URL.Query()
+	opts := webSocketOptions{
+		upgrade: websocket.UpgradeOptions{Deflate: query.Get("deflate") != "false"},
+		info:    query.Get("info") == "true",
+	}
+
+	if protocols := query.Get("protocols"); protocols != "" {
+		for _, p := range strings.Split(protocols, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				opts.upgrade.Subprotocols = append(opts.upgrade.Subprotocols, p)
+			}
+		}
+	}
+
+	if v := query.Get("close"); v != "" {
+		code, err := strconv.Atoi(v)
+		if err != nil || !websocket.IsValidCloseCode(code) {
+			return opts, errors.New("invalid close code")
+		}
+		opts.closeCode = code
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return opts, errors.New("invalid message limit")
+		}
+		opts.limit = limit
+	}
+
+	return opts, nil
+}
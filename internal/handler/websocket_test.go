@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Elagoht/echobox/internal/websocket"
+)
+
+func dialWebSocket(t *testing.T, query string, headers map[string]string) *websocket.Conn {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(WebSocket))
+	t.Cleanup(server.Close)
+
+	netConn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { netConn.Close() })
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/ws"+query, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	conn, _, err := websocket.Dial(netConn, req, headers["Sec-WebSocket-Extensions"] != "")
+	if err != nil {
+		t.Fatalf("Handshake failed: %v", err)
+	}
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) (int, []byte) {
+	t.Helper()
+
+	op, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	return op, data
+}
+
+func TestWebSocket_Echo(t *testing.T) {
+	conn := dialWebSocket(t, "", nil)
+
+	messages := []struct {
+		op   int
+		data string
+	}{
+		{websocket.OpText, "hello"},
+		{websocket.OpBinary, "\x00\x01\x02"},
+	}
+
+	for _, m := range messages {
+		if err := conn.WriteMessage(m.op, []byte(m.data)); err != nil {
+			t.Fatalf("WriteMessage() error = %v", err)
+		}
+		op, data := readMessage(t, conn)
+		if op != m.op || string(data) != m.data {
+			t.Errorf("WebSocket() echoed %v %q, want %v %q", op, data, m.op, m.data)
+		}
+	}
+}
+
+func TestWebSocket_PingPong(t *testing.T) {
+	conn := dialWebSocket(t, "", nil)
+
+	conn.WriteMessage(websocket.OpPing, []byte("are you there"))
+	op, data := readMessage(t, conn)
+	if op != websocket.OpPong || string(data) != "are you there" {
+		t.Errorf("WebSocket() ping answered with %v %q, want pong", op, data)
+	}
+}
+
+func TestWebSocket_CloseCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		sendCode int
+		wantCode int
+	}{
+		{"mirrors client code", "", 4001, 4001},
+		{"configured close code", "?close=4321", websocket.CloseNormal, 4321},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := dialWebSocket(t, tt.query, nil)
+
+			conn.WriteClose(tt.sendCode, "bye")
+			op, data := readMessage(t, conn)
+			if op != websocket.OpClose {
+				t.Fatalf("WebSocket() replied with opcode %v, want close", op)
+			}
+			code, _, err := websocket.ParseClose(data)
+			if err != nil || code != tt.wantCode {
+				t.Errorf("WebSocket() close code = %v (%v), want %v", code, err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestWebSocket_Limit(t *testing.T) {
+	conn := dialWebSocket(t, "?limit=1&close=4000", nil)
+
+	conn.WriteMessage(websocket.OpText, []byte("only one"))
+	readMessage(t, conn)
+
+	op, data := readMessage(t, conn)
+	code, _, _ := websocket.ParseClose(data)
+	if op != websocket.OpClose || code != 4000 {
+		t.Errorf("WebSocket() after limit = %v code %v, want close 4000", op, code)
+	}
+}
+
+func TestWebSocket_Subprotocol(t *testing.T) {
+	conn := dialWebSocket(t, "?protocols=json,chat", map[string]string{
+		"Sec-WebSocket-Protocol": "chat, json",
+	})
+
+	if conn.Subprotocol != "json" {
+		t.Errorf("WebSocket() subprotocol = %q, want json", conn.Subprotocol)
+	}
+}
+
+func TestWebSocket_Deflate(t *testing.T) {
+	conn := dialWebSocket(t, "", map[string]string{
+		"Sec-WebSocket-Extensions": "permessage-deflate; client_max_window_bits",
+	})
+
+	if !conn.Compressed() {
+		t.Fatal("WebSocket() did not negotiate permessage-deflate")
+	}
+
+	conn.WriteMessage(websocket.OpText, []byte("compressed hello"))
+	if _, data := readMessage(t, conn); string(data) != "compressed hello" {
+		t.Errorf("WebSocket() echoed %q, want compressed hello", data)
+	}
+}
+
+func TestWebSocket_Info(t *testing.T) {
+	conn := dialWebSocket(t, "?info=true", map[string]string{"X-Custom-Header": "test-value"})
+
+	op, data := readMessage(t, conn)
+	if op != websocket.OpText {
+		t.Fatalf("WebSocket() info opcode = %v, want text", op)
+	}
+
+	var info WebSocketInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		t.Fatalf("Failed to decode info: %v", err)
+	}
+	if info.Headers["X-Custom-Header"][0] != "test-value" {
+		t.Errorf("WebSocket() info headers = %v, want X-Custom-Header", info.Headers)
+	}
+}
+
+func TestWebSocket_InvalidOptions(t *testing.T) {
+	for _, query := range []string{"?close=1005", "?close=abc", "?limit=0"} {
+		t.Run(query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ws"+query, nil)
+			w := httptest.NewRecorder()
+
+			WebSocket(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("WebSocket() status = %v, want %v", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestWebSocket_NotUpgrade(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	w := httptest.NewRecorder()
+
+	WebSocket(w, req)
+
+	if w.Code != http.StatusUpgradeRequired {
+		t.Errorf("WebSocket() status = %v, want %v", w.Code, http.StatusUpgradeRequired)
+	}
+	if w.Header().Get("Upgrade") != "websocket" {
+		t.Errorf("WebSocket() Upgrade header = %q, want websocket", w.Header().Get("Upgrade"))
+	}
+}
@@ -0,0 +1,142 @@
+package health
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Mode is how /_health answers, set through the admin API.
+type Mode string
+
+const (
+	// Healthy answers 200.
+	Healthy Mode = "healthy"
+	// Unhealthy answers 503.
+	Unhealthy Mode = "unhealthy"
+	// Degraded answers 200 but reports "degraded", for checks that read
+	// the body.
+	Degraded Mode = "degraded"
+	// Slow answers 200 after Delay, to trip probe timeouts.
+	Slow Mode = "slow"
+	// Flapping alternates between healthy and unhealthy every Period.
+	Flapping Mode = "flapping"
+)
+
+const (
+	DefaultSlowDelay      = 5 * time.Second
+	DefaultFlappingPeriod = 30 * time.Second
+)
+
+// Duration reads and writes Go duration strings such as "1500ms" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", b)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Setting is the health behaviour read and written by the admin API.
+type Setting struct {
+	Mode   Mode     `json:"mode"`
+	Delay  Duration `json:"delay,omitempty"`
+	Period Duration `json:"period,omitempty"`
+}
+
+// Result is what a health check answers with.
+type Result struct {
+	Healthy bool
+	Status  string
+	// Delay is how long to wait before answering.
+	Delay time.Duration
+}
+
+// State is the health and readiness reported by the probe endpoints.
+type State struct {
+	ready atomic.Bool
+
+	mu      sync.Mutex
+	setting Setting
+	since   time.Time
+}
+
+// New returns a State that is healthy and ready to serve.
+func New() *State {
+	s := &State{setting: Setting{Mode: Healthy}, since: time.Now()}
+	s.ready.Store(true)
+	return s
+}
+
+func (s *State) Ready() bool {
+	return s.ready.Load()
+}
+
+func (s *State) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+// Set validates setting, fills in default durations and applies it.
+// Flapping restarts from healthy.
+func (s *State) Set(setting Setting) (Setting, error) {
+	if setting.Delay < 0 || setting.Period < 0 {
+		return Setting{}, fmt.Errorf("durations must not be negative")
+	}
+	switch setting.Mode {
+	case Healthy, Unhealthy, Degraded:
+		setting.Delay, setting.Period = 0, 0
+	case Slow:
+		setting.Period = 0
+		if setting.Delay == 0 {
+			setting.Delay = Duration(DefaultSlowDelay)
+		}
+	case Flapping:
+		setting.Delay = 0
+		if setting.Period == 0 {
+			setting.Period = Duration(DefaultFlappingPeriod)
+		}
+	default:
+		return Setting{}, fmt.Errorf("mode must be healthy, unhealthy, degraded, slow or flapping, got %q", setting.Mode)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setting = setting
+	s.since = time.Now()
+	return setting, nil
+}
+
+func (s *State) Setting() Setting {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setting
+}
+
+// Check reports how /_health should answer at now.
+func (s *State) Check(now time.Time) Result {
+	s.mu.Lock()
+	setting, since := s.setting, s.since
+	s.mu.Unlock()
+
+	switch setting.Mode {
+	case Unhealthy:
+		return Result{Status: "unhealthy"}
+	case Degraded:
+		return Result{Healthy: true, Status: "degraded"}
+	case Slow:
+		return Result{Healthy: true, Status: "ok", Delay: time.Duration(setting.Delay)}
+	case Flapping:
+		if (now.Sub(since)/time.Duration(setting.Period))%2 == 1 {
+			return Result{Status: "unhealthy"}
+		}
+	}
+	return Result{Healthy: true, Status: "ok"}
+}
@@ -0,0 +1,93 @@
+package health
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestState_Set(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting Setting
+		want    Setting
+		wantErr bool
+	}{
+		{"healthy", Setting{Mode: Healthy, Delay: Duration(time.Second)}, Setting{Mode: Healthy}, false},
+		{"slow default delay", Setting{Mode: Slow}, Setting{Mode: Slow, Delay: Duration(DefaultSlowDelay)}, false},
+		{"slow delay", Setting{Mode: Slow, Delay: Duration(time.Second)}, Setting{Mode: Slow, Delay: Duration(time.Second)}, false},
+		{"flapping default period", Setting{Mode: Flapping}, Setting{Mode: Flapping, Period: Duration(DefaultFlappingPeriod)}, false},
+		{"unknown mode", Setting{Mode: "sick"}, Setting{}, true},
+		{"negative delay", Setting{Mode: Slow, Delay: -1}, Setting{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New()
+			got, err := s.Set(tt.setting)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Set() = %+v, want %+v", got, tt.want)
+			}
+			if tt.wantErr && s.Setting().Mode != Healthy {
+				t.Errorf("Setting() = %+v after a failed Set, want it unchanged", s.Setting())
+			}
+		})
+	}
+}
+
+func TestState_Check(t *testing.T) {
+	s := New()
+	now := time.Now()
+
+	if got := s.Check(now); !got.Healthy || got.Status != "ok" {
+		t.Errorf("Check() = %+v, want healthy by default", got)
+	}
+
+	s.Set(Setting{Mode: Degraded})
+	if got := s.Check(now); !got.Healthy || got.Status != "degraded" {
+		t.Errorf("Check() degraded = %+v, want healthy with status degraded", got)
+	}
+
+	s.Set(Setting{Mode: Slow, Delay: Duration(time.Second)})
+	if got := s.Check(now); !got.Healthy || got.Delay != time.Second {
+		t.Errorf("Check() slow = %+v, want healthy after 1s", got)
+	}
+
+	s.Set(Setting{Mode: Flapping, Period: Duration(time.Minute)})
+	start := s.since
+	for _, tt := range []struct {
+		at   time.Duration
+		want bool
+	}{
+		{0, true},
+		{59 * time.Second, true},
+		{61 * time.Second, false},
+		{2*time.Minute + time.Second, true},
+	} {
+		if got := s.Check(start.Add(tt.at)); got.Healthy != tt.want {
+			t.Errorf("Check() flapping at %v healthy = %v, want %v", tt.at, got.Healthy, tt.want)
+		}
+	}
+}
+
+func TestSetting_JSON(t *testing.T) {
+	var s Setting
+	if err := json.Unmarshal([]byte(`{"mode":"slow","delay":"1500ms"}`), &s); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if s.Mode != Slow || time.Duration(s.Delay) != 1500*time.Millisecond {
+		t.Errorf("Unmarshal() = %+v, want slow with a 1.5s delay", s)
+	}
+
+	b, _ := json.Marshal(s)
+	if string(b) != `{"mode":"slow","delay":"1.5s"}` {
+		t.Errorf("Marshal() = %s, want the delay as a duration string", b)
+	}
+
+	if err := json.Unmarshal([]byte(`{"mode":"slow","delay":"soon"}`), &s); err == nil {
+		t.Error("Unmarshal() with an invalid delay error = nil, want an error")
+	}
+}
@@ -0,0 +1,83 @@
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+)
+
+// Address is a parsed listen address such as "tcp://127.0.0.1:8080",
+// "[::1]:8080", ":8080" or "unix:///run/echobox.sock".
+type Address struct {
+	Network string
+	Address string
+}
+
+func Parse(s string) (Address, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Address{}, errors.New("empty listen address")
+	}
+
+	network, rest, found := strings.Cut(s, "://")
+	if !found {
+		if path, ok := strings.CutPrefix(s, "unix:"); ok {
+			network, rest = "unix", path
+		} else {
+			network, rest = "tcp", s
+		}
+	}
+
+	switch network {
+	case "unix":
+		if rest == "" {
+			return Address{}, fmt.Errorf("listen address %q: missing socket path", s)
+		}
+		return Address{Network: network, Address: rest}, nil
+	case "tcp", "tcp4", "tcp6":
+		if !strings.Contains(rest, ":") {
+			// A bare port listens on every interface
+			rest = ":" + rest
+		}
+		if _, _, err := net.SplitHostPort(rest); err != nil {
+			return Address{}, fmt.Errorf("listen address %q: %w", s, err)
+		}
+		return Address{Network: network, Address: rest}, nil
+	default:
+		return Address{}, fmt.Errorf("listen address %q: unsupported network %q", s, network)
+	}
+}
+
+func (a Address) String() string {
+	return a.Network + "://" + a.Address
+}
+
+// Listen opens a listener for addr. Unix sockets replace a stale socket file
+// left by a previous run and get socketMode as their permissions.
+func Listen(addr Address, socketMode fs.FileMode) (net.Listener, error) {
+	if addr.Network != "unix" {
+		return net.Listen(addr.Network, addr.Address)
+	}
+
+	if info, err := os.Lstat(addr.Address); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", addr.Address)
+		}
+		if err := os.Remove(addr.Address); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", addr.Address)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(addr.Address, socketMode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
@@ -0,0 +1,104 @@
+package listener
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Address
+		wantErr bool
+	}{
+		{input: "8080", want: Address{"tcp", ":8080"}},
+		{input: ":8080", want: Address{"tcp", ":8080"}},
+		{input: "127.0.0.1:8080", want: Address{"tcp", "127.0.0.1:8080"}},
+		{input: "[::1]:8080", want: Address{"tcp", "[::1]:8080"}},
+		{input: "tcp6://[::]:8080", want: Address{"tcp6", "[::]:8080"}},
+		{input: "tcp4://0.0.0.0:8080", want: Address{"tcp4", "0.0.0.0:8080"}},
+		{input: "unix:///run/echobox.sock", want: Address{"unix", "/run/echobox.sock"}},
+		{input: "unix:echobox.sock", want: Address{"unix", "echobox.sock"}},
+		{input: "", wantErr: true},
+		{input: "unix://", wantErr: true},
+		{input: "udp://:8080", wantErr: true},
+		{input: "tcp://[::1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListen_TCP(t *testing.T) {
+	ln, err := Listen(Address{"tcp", "127.0.0.1:0"}, 0)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer ln.Close()
+
+	if _, ok := ln.Addr().(*net.TCPAddr); !ok {
+		t.Errorf("Listen() addr = %T, want *net.TCPAddr", ln.Addr())
+	}
+}
+
+func TestListen_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "echobox.sock")
+
+	ln, err := Listen(Address{"unix", path}, 0o600)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Socket file missing: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Socket mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to dial socket: %v", err)
+	}
+	conn.Close()
+	ln.Close()
+}
+
+func TestListen_UnixStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "echobox.sock")
+
+	// Leave a socket file behind as a crashed process would
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to create stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := Listen(Address{"unix", path}, 0o660)
+	if err != nil {
+		t.Fatalf("Listen() over stale socket error = %v", err)
+	}
+	ln.Close()
+}
+
+func TestListen_UnixRefusesRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "not-a-socket")
+	os.WriteFile(path, []byte("data"), 0o600)
+
+	if _, err := Listen(Address{"unix", path}, 0o660); err == nil {
+		t.Error("Listen() error = nil, want refusal to replace a regular file")
+	}
+}
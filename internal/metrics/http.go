@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Elagoht/echobox/internal/response"
+	"github.com/Elagoht/echobox/internal/version"
+)
+
+// Default holds the metrics echobox exposes at /metrics.
+var Default = NewRegistry()
+
+var (
+	requests = Default.NewCounterVec("echobox_http_requests_total",
+		"HTTP requests served, by route, method and status.", "route", "method", "status")
+	duration = Default.NewHistogramVec("echobox_http_request_duration_seconds",
+		"Time taken to serve HTTP requests.", DefaultBuckets, "route", "method")
+	inFlight = Default.NewGauge("echobox_http_requests_in_flight",
+		"HTTP requests currently being served.")
+	bytesIn = Default.NewCounterVec("echobox_http_request_bytes_total",
+		"Request body bytes read, by route.", "route")
+	bytesOut = Default.NewCounterVec("echobox_http_response_bytes_total",
+		"Response body bytes written, by route.", "route")
+	streams = Default.NewGaugeVec("echobox_streaming_connections",
+		"Open streaming connections, by kind.", "kind")
+)
+
+func init() {
+	info := version.Get()
+	Default.NewGaugeVec("echobox_build_info", "Build information, always 1.", "version", "go_version").
+		WithLabelValues(info.Version, info.GoVersion).Set(1)
+	Default.NewGauge("echobox_start_time_seconds", "Unix time the process started.").
+		Set(float64(time.Now().Unix()))
+}
+
+// Streams tracks open streaming connections of kind, such as "websocket".
+// Callers Inc when a stream opens and Dec when it ends.
+func Streams(kind string) *Gauge {
+	return streams.WithLabelValues(kind)
+}
+
+// Handler serves the Default registry in the text exposition format.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := Default.WriteTo(w); err != nil {
+		log.Printf("Error writing metrics: %v", err)
+	}
+}
+
+// Middleware records every request in the Default registry. Routes are the
+// ServeMux pattern that matched, so arbitrary echo paths share one label.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &response.Recorder{ResponseWriter: w}
+		var body *countingReader
+		if r.Body != nil && r.Body != http.NoBody {
+			body = &countingReader{ReadCloser: r.Body}
+			r.Body = body
+		}
+
+		inFlight.Inc()
+		defer func() {
+			inFlight.Dec()
+
+			status := rw.Status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := r.Pattern
+			if _, path, ok := strings.Cut(route, " "); ok {
+				// Methods have their own label
+				route = path
+			}
+			if route == "" {
+				route = "unmatched"
+			}
+			requests.WithLabelValues(route, r.Method, strconv.Itoa(status)).Inc()
+			duration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+			bytesOut.WithLabelValues(route).Add(float64(rw.Bytes))
+			if body != nil {
+				bytesIn.WithLabelValues(route).Add(float64(body.n))
+			}
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
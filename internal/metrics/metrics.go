@@ -0,0 +1,318 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Registry holds metric families and writes them in the Prometheus text
+// exposition format.
+type Registry struct {
+	mu       sync.Mutex
+	families []family
+}
+
+type family interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.families {
+		if existing.name() == f.name() {
+			panic("metrics: duplicate metric " + f.name())
+		}
+	}
+	r.families = append(r.families, f)
+}
+
+// WriteTo writes every family, sorted by name.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	families := append([]family(nil), r.families...)
+	r.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name() < families[j].name() })
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, f := range families {
+		f.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// value is a float64 updated atomically.
+type value struct {
+	bits atomic.Uint64
+}
+
+func (v *value) Add(delta float64) {
+	for {
+		old := v.bits.Load()
+		if v.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (v *value) Set(f float64) {
+	v.bits.Store(math.Float64bits(f))
+}
+
+func (v *value) Get() float64 {
+	return math.Float64frombits(v.bits.Load())
+}
+
+type Counter struct{ value }
+
+func (c *Counter) Inc() { c.Add(1) }
+
+type Gauge struct{ value }
+
+func (g *Gauge) Inc() { g.Add(1) }
+func (g *Gauge) Dec() { g.Add(-1) }
+
+// vec maps label values to series, creating them on first use.
+type vec[T any] struct {
+	metricName string
+	help       string
+	kind       string
+	labels     []string
+
+	mu     sync.Mutex
+	series map[string]*T
+	values map[string][]string
+	create func() *T
+}
+
+func newVec[T any](name, help, kind string, labels []string, create func() *T) *vec[T] {
+	return &vec[T]{
+		metricName: name,
+		help:       help,
+		kind:       kind,
+		labels:     labels,
+		series:     make(map[string]*T),
+		values:     make(map[string][]string),
+		create:     create,
+	}
+}
+
+func (v *vec[T]) name() string {
+	return v.metricName
+}
+
+func (v *vec[T]) with(values ...string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", v.metricName, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = v.create()
+		v.series[key] = s
+		v.values[key] = append([]string(nil), values...)
+	}
+	return s
+}
+
+// each calls fn for every series in label order.
+func (v *vec[T]) each(fn func(labels string, s *T)) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	type entry struct {
+		labels string
+		series *T
+	}
+	entries := make([]entry, len(keys))
+	for i, k := range keys {
+		entries[i] = entry{formatLabels(v.labels, v.values[k]), v.series[k]}
+	}
+	v.mu.Unlock()
+
+	for _, e := range entries {
+		fn(e.labels, e.series)
+	}
+}
+
+func (v *vec[T]) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.metricName, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.metricName, v.kind)
+}
+
+type CounterVec struct{ *vec[Counter] }
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, "counter", labels, func() *Counter { return &Counter{} })}
+	r.register(c)
+	return c
+}
+
+func (c *CounterVec) WithLabelValues(values ...string) *Counter {
+	return c.with(values...)
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.writeHeader(w)
+	c.each(func(labels string, s *Counter) {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, labels, formatFloat(s.Get()))
+	})
+}
+
+type GaugeVec struct{ *vec[Gauge] }
+
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newVec(name, help, "gauge", labels, func() *Gauge { return &Gauge{} })}
+	r.register(g)
+	return g
+}
+
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	return r.NewGaugeVec(name, help).WithLabelValues()
+}
+
+func (g *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	return g.with(values...)
+}
+
+func (g *GaugeVec) write(w *bufio.Writer) {
+	g.writeHeader(w)
+	g.each(func(labels string, s *Gauge) {
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, labels, formatFloat(s.Get()))
+	})
+}
+
+type Histogram struct {
+	buckets []float64
+	counts  []atomic.Uint64
+	count   atomic.Uint64
+	sum     value
+}
+
+func (h *Histogram) Observe(v float64) {
+	// Buckets are cumulative when written; here each observation lands in
+	// the first bucket that holds it
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		h.counts[i].Add(1)
+	}
+	h.count.Add(1)
+	h.sum.Add(v)
+}
+
+type HistogramVec struct {
+	*vec[Histogram]
+	buckets []float64
+}
+
+// DefaultBuckets suit request latencies in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{
+		vec: newVec(name, help, "histogram", labels, func() *Histogram {
+			return &Histogram{buckets: buckets, counts: make([]atomic.Uint64, len(buckets))}
+		}),
+		buckets: buckets,
+	}
+	r.register(h)
+	return h
+}
+
+func (h *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return h.with(values...)
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w)
+	h.each(func(labels string, s *Histogram) {
+		// Read the total first so buckets never exceed +Inf mid-update
+		count := s.count.Load()
+		var cumulative uint64
+		for i, le := range s.buckets {
+			cumulative += s.counts[i].Load()
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, withLabel(labels, "le", formatFloat(le)), min(cumulative, count))
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, withLabel(labels, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, labels, formatFloat(s.sum.Get()))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, labels, count)
+	})
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// withLabel adds one more label to already formatted labels.
+func withLabel(labels, name, val string) string {
+	pair := name + `="` + escapeLabel(val) + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
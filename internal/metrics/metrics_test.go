@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_requests_total", "Requests.", "path")
+	c.WithLabelValues("/b").Add(2)
+	c.WithLabelValues(`/a"\`).Inc()
+	r.NewGauge("test_open", "Open things.\nTwo lines.").Set(3)
+
+	h := r.NewHistogramVec("test_seconds", "Latency.", []float64{1, 0.1}, "op")
+	h.WithLabelValues("read").Observe(0.05)
+	h.WithLabelValues("read").Observe(0.5)
+	h.WithLabelValues("read").Observe(5)
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+
+	want := `# HELP test_open Open things.\nTwo lines.
+# TYPE test_open gauge
+test_open 3
+# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{path="/a\"\\"} 1
+test_requests_total{path="/b"} 2
+# HELP test_seconds Latency.
+# TYPE test_seconds histogram
+test_seconds_bucket{op="read",le="0.1"} 1
+test_seconds_bucket{op="read",le="1"} 2
+test_seconds_bucket{op="read",le="+Inf"} 3
+test_seconds_sum{op="read"} 5.55
+test_seconds_count{op="read"} 3
+`
+	if b.String() != want {
+		t.Errorf("WriteTo() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestRegistry_Panics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(r *Registry)
+	}{
+		{"duplicate name", func(r *Registry) {
+			r.NewGauge("dup", "")
+			r.NewGauge("dup", "")
+		}},
+		{"wrong label count", func(r *Registry) {
+			r.NewCounterVec("c", "", "a", "b").WithLabelValues("x")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s did not panic", tt.name)
+				}
+			}()
+			tt.fn(NewRegistry())
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /upload/{name}", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done"))
+	})
+	handler := Middleware(mux)
+
+	for _, name := range []string{"a", "b"} {
+		req := httptest.NewRequest(http.MethodPut, "/upload/"+name, strings.NewReader("12345"))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	counter := requests.WithLabelValues("/upload/{name}", http.MethodPut, "201").Get()
+	if counter != 2 {
+		t.Errorf("requests{route=/upload/{name}} = %v, want 2", counter)
+	}
+	if got := bytesIn.WithLabelValues("/upload/{name}").Get(); got != 10 {
+		t.Errorf("request bytes = %v, want 10", got)
+	}
+	if got := bytesOut.WithLabelValues("/upload/{name}").Get(); got != 8 {
+		t.Errorf("response bytes = %v, want 8", got)
+	}
+	if got := inFlight.Get(); got != 0 {
+		t.Errorf("in flight = %v, want 0 after requests finish", got)
+	}
+
+	w := httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		`echobox_http_requests_total{route="/upload/{name}",method="PUT",status="201"} 2`,
+		`echobox_http_request_duration_seconds_count{route="/upload/{name}",method="PUT"} 2`,
+		"# TYPE echobox_build_info gauge",
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("Handler() output missing %q", want)
+		}
+	}
+}
+
+func TestStreams(t *testing.T) {
+	g := Streams("test")
+	g.Inc()
+	g.Inc()
+	g.Dec()
+	if got := Streams("test").Get(); got != 1 {
+		t.Errorf("Streams(test) = %v, want 1", got)
+	}
+}
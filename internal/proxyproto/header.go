@@ -0,0 +1,265 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	v1Prefix    = []byte("PROXY ")
+	v2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+	ErrNoHeader = errors.New("proxyproto: no PROXY header")
+)
+
+// v1MaxLength is the longest valid v1 header, CRLF included.
+const v1MaxLength = 107
+
+// Header is a decoded PROXY protocol header.
+type Header struct {
+	Version     int    `json:"version"`
+	Command     string `json:"command"`
+	Protocol    string `json:"protocol"`
+	Source      string `json:"source,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	TLVs        []TLV  `json:"tlvs,omitempty"`
+
+	sourceAddr net.Addr
+}
+
+// SourceAddr is the original client address, or nil for LOCAL and UNKNOWN
+// headers, which carry no usable address.
+func (h *Header) SourceAddr() net.Addr {
+	return h.sourceAddr
+}
+
+type TLV struct {
+	Type  byte
+	Value []byte
+}
+
+var tlvNames = map[byte]string{
+	0x01: "alpn",
+	0x02: "authority",
+	0x03: "crc32c",
+	0x04: "noop",
+	0x05: "unique_id",
+	0x20: "ssl",
+	0x30: "netns",
+	0xE0: "gcp",
+	0xEA: "aws",
+	0xEE: "azure",
+}
+
+func (t TLV) Name() string {
+	if name, ok := tlvNames[t.Type]; ok {
+		return name
+	}
+	return "0x" + hex.EncodeToString([]byte{t.Type})
+}
+
+func (t TLV) MarshalJSON() ([]byte, error) {
+	out := struct {
+		Type  byte   `json:"type"`
+		Name  string `json:"name"`
+		Value string `json:"value,omitempty"`
+		Hex   string `json:"hex"`
+	}{
+		Type: t.Type,
+		Name: t.Name(),
+		Hex:  hex.EncodeToString(t.Value),
+	}
+	if printable(t.Value) {
+		out.Value = string(t.Value)
+	}
+	return json.Marshal(out)
+}
+
+func printable(b []byte) bool {
+	if len(b) == 0 || !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Read decodes a v1 or v2 header from the start of br. It returns ErrNoHeader,
+// consuming nothing, when the stream does not begin with a PROXY header.
+func Read(br *bufio.Reader) (*Header, error) {
+	first, err := br.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch first[0] {
+	case v1Prefix[0]:
+		if prefix, err := br.Peek(len(v1Prefix)); err != nil || !bytes.Equal(prefix, v1Prefix) {
+			return nil, ErrNoHeader
+		}
+		return readV1(br)
+	case v2Signature[0]:
+		if sig, err := br.Peek(len(v2Signature)); err != nil || !bytes.Equal(sig, v2Signature) {
+			return nil, ErrNoHeader
+		}
+		return readV2(br)
+	}
+	return nil, ErrNoHeader
+}
+
+func readV1(br *bufio.Reader) (*Header, error) {
+	var line []byte
+	for len(line) < v1MaxLength {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("proxyproto: reading v1 header: %w", err)
+		}
+		line = append(line, b)
+		if bytes.HasSuffix(line, []byte("\r\n")) {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errors.New("proxyproto: v1 header too long")
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("proxyproto: malformed v1 header %q", line)
+	}
+	h := &Header{Version: 1, Command: "PROXY", Protocol: fields[1]}
+
+	switch fields[1] {
+	case "UNKNOWN":
+		return h, nil
+	case "TCP4", "TCP6":
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("proxyproto: malformed v1 header %q", line)
+		}
+	default:
+		return nil, fmt.Errorf("proxyproto: unknown v1 protocol %q", fields[1])
+	}
+
+	src, err := parseV1Addr(fields[1], fields[2], fields[4])
+	if err != nil {
+		return nil, err
+	}
+	dst, err := parseV1Addr(fields[1], fields[3], fields[5])
+	if err != nil {
+		return nil, err
+	}
+
+	h.sourceAddr = src
+	h.Source = src.String()
+	h.Destination = dst.String()
+	return h, nil
+}
+
+func parseV1Addr(protocol, ip, port string) (*net.TCPAddr, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil || (protocol == "TCP4") != (addr.To4() != nil) {
+		return nil, fmt.Errorf("proxyproto: invalid %s address %q", protocol, ip)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("proxyproto: invalid port %q", port)
+	}
+	return &net.TCPAddr{IP: addr, Port: int(p)}, nil
+}
+
+func readV2(br *bufio.Reader) (*Header, error) {
+	var fixed [16]byte
+	if _, err := io.ReadFull(br, fixed[:]); err != nil {
+		return nil, fmt.Errorf("proxyproto: reading v2 header: %w", err)
+	}
+
+	if version := fixed[12] >> 4; version != 2 {
+		return nil, fmt.Errorf("proxyproto: unsupported version %d", version)
+	}
+
+	h := &Header{Version: 2}
+	switch fixed[12] & 0x0F {
+	case 0x0:
+		h.Command = "LOCAL"
+	case 0x1:
+		h.Command = "PROXY"
+	default:
+		return nil, fmt.Errorf("proxyproto: unknown v2 command %#x", fixed[12]&0x0F)
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(fixed[14:]))
+	if _, err := io.ReadFull(br, payload); err != nil {
+		return nil, fmt.Errorf("proxyproto: reading v2 addresses: %w", err)
+	}
+
+	family, transport := fixed[13]>>4, fixed[13]&0x0F
+	var addrLen int
+	switch family {
+	case 0x0:
+		h.Protocol = "UNSPEC"
+	case 0x1:
+		h.Protocol, addrLen = "TCP4", 12
+	case 0x2:
+		h.Protocol, addrLen = "TCP6", 36
+	case 0x3:
+		h.Protocol, addrLen = "UNIX", 216
+	default:
+		return nil, fmt.Errorf("proxyproto: unknown address family %#x", family)
+	}
+	if transport == 0x2 && family != 0x0 {
+		h.Protocol = strings.Replace(h.Protocol, "TCP", "UDP", 1)
+		if family == 0x3 {
+			h.Protocol = "UNIXGRAM"
+		}
+	}
+
+	if len(payload) < addrLen {
+		return nil, errors.New("proxyproto: v2 address block too short")
+	}
+	addrs, rest := payload[:addrLen], payload[addrLen:]
+
+	// LOCAL connections come from the proxy itself; their addresses are ignored
+	if h.Command == "PROXY" {
+		switch family {
+		case 0x1, 0x2:
+			ipLen := addrLen/2 - 2
+			src := &net.TCPAddr{IP: net.IP(addrs[:ipLen]), Port: int(binary.BigEndian.Uint16(addrs[2*ipLen:]))}
+			dst := &net.TCPAddr{IP: net.IP(addrs[ipLen : 2*ipLen]), Port: int(binary.BigEndian.Uint16(addrs[2*ipLen+2:]))}
+			h.sourceAddr = src
+			h.Source, h.Destination = src.String(), dst.String()
+		case 0x3:
+			src := &net.UnixAddr{Name: string(bytes.TrimRight(addrs[:108], "\x00")), Net: "unix"}
+			h.sourceAddr = src
+			h.Source = src.Name
+			h.Destination = string(bytes.TrimRight(addrs[108:], "\x00"))
+		}
+	}
+
+	for len(rest) > 0 {
+		if len(rest) < 3 {
+			return nil, errors.New("proxyproto: truncated TLV")
+		}
+		length := int(binary.BigEndian.Uint16(rest[1:3]))
+		if len(rest) < 3+length {
+			return nil, errors.New("proxyproto: truncated TLV")
+		}
+		h.TLVs = append(h.TLVs, TLV{Type: rest[0], Value: rest[3 : 3+length]})
+		rest = rest[3+length:]
+	}
+
+	return h, nil
+}
@@ -0,0 +1,187 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func v2Header(command, family byte, addrs []byte, tlvs ...TLV) []byte {
+	var payload bytes.Buffer
+	payload.Write(addrs)
+	for _, tlv := range tlvs {
+		payload.WriteByte(tlv.Type)
+		binary.Write(&payload, binary.BigEndian, uint16(len(tlv.Value)))
+		payload.Write(tlv.Value)
+	}
+
+	var b bytes.Buffer
+	b.Write(v2Signature)
+	b.WriteByte(0x20 | command)
+	b.WriteByte(family)
+	binary.Write(&b, binary.BigEndian, uint16(payload.Len()))
+	b.Write(payload.Bytes())
+	return b.Bytes()
+}
+
+func TestRead_V1(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		want     Header
+		wantRest string
+		wantErr  bool
+	}{
+		{
+			name:     "TCP4",
+			input:    "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nGET / HTTP/1.1\r\n",
+			want:     Header{Version: 1, Command: "PROXY", Protocol: "TCP4", Source: "192.0.2.1:56324", Destination: "198.51.100.1:443"},
+			wantRest: "GET / HTTP/1.1\r\n",
+		},
+		{
+			name:  "TCP6",
+			input: "PROXY TCP6 2001:db8::1 2001:db8::2 4000 80\r\n",
+			want:  Header{Version: 1, Command: "PROXY", Protocol: "TCP6", Source: "[2001:db8::1]:4000", Destination: "[2001:db8::2]:80"},
+		},
+		{
+			name:  "UNKNOWN",
+			input: "PROXY UNKNOWN ffff::1 ffff::2 1 2\r\n",
+			want:  Header{Version: 1, Command: "PROXY", Protocol: "UNKNOWN"},
+		},
+		{name: "wrong family", input: "PROXY TCP4 2001:db8::1 2001:db8::2 4000 80\r\n", wantErr: true},
+		{name: "bad port", input: "PROXY TCP4 192.0.2.1 198.51.100.1 99999 443\r\n", wantErr: true},
+		{name: "missing fields", input: "PROXY TCP4 192.0.2.1\r\n", wantErr: true},
+		{name: "too long", input: "PROXY " + strings.Repeat("x", 200), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			br := bufio.NewReader(strings.NewReader(tt.input))
+			got, err := Read(br)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got.sourceAddr = nil
+			if got.Version != tt.want.Version || got.Command != tt.want.Command || got.Protocol != tt.want.Protocol ||
+				got.Source != tt.want.Source || got.Destination != tt.want.Destination {
+				t.Errorf("Read() = %+v, want %+v", got, tt.want)
+			}
+
+			rest, _ := io.ReadAll(br)
+			if string(rest) != tt.wantRest {
+				t.Errorf("Read() left %q, want %q", rest, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestRead_V2(t *testing.T) {
+	ipv4 := []byte{192, 0, 2, 1, 198, 51, 100, 1, 0xDC, 0x04, 0x01, 0xBB}
+	ipv6 := append(append(net.ParseIP("2001:db8::1").To16(), net.ParseIP("2001:db8::2").To16()...), 0x0F, 0xA0, 0x00, 0x50)
+	unix := make([]byte, 216)
+	copy(unix, "/run/client.sock")
+	copy(unix[108:], "/run/server.sock")
+
+	tests := []struct {
+		name    string
+		input   []byte
+		want    Header
+		wantErr bool
+	}{
+		{
+			name:  "PROXY TCP4 with TLVs",
+			input: v2Header(0x1, 0x11, ipv4, TLV{0x01, []byte("h2")}, TLV{0x02, []byte("example.com")}),
+			want: Header{Version: 2, Command: "PROXY", Protocol: "TCP4", Source: "192.0.2.1:56324", Destination: "198.51.100.1:443",
+				TLVs: []TLV{{0x01, []byte("h2")}, {0x02, []byte("example.com")}}},
+		},
+		{
+			name:  "PROXY TCP6",
+			input: v2Header(0x1, 0x21, ipv6),
+			want:  Header{Version: 2, Command: "PROXY", Protocol: "TCP6", Source: "[2001:db8::1]:4000", Destination: "[2001:db8::2]:80"},
+		},
+		{
+			name:  "PROXY UDP4",
+			input: v2Header(0x1, 0x12, ipv4),
+			want:  Header{Version: 2, Command: "PROXY", Protocol: "UDP4", Source: "192.0.2.1:56324", Destination: "198.51.100.1:443"},
+		},
+		{
+			name:  "PROXY UNIX",
+			input: v2Header(0x1, 0x31, unix),
+			want:  Header{Version: 2, Command: "PROXY", Protocol: "UNIX", Source: "/run/client.sock", Destination: "/run/server.sock"},
+		},
+		{
+			name:  "LOCAL ignores addresses",
+			input: v2Header(0x0, 0x11, ipv4),
+			want:  Header{Version: 2, Command: "LOCAL", Protocol: "TCP4"},
+		},
+		{name: "unknown command", input: v2Header(0x2, 0x11, ipv4), wantErr: true},
+		{name: "short address block", input: v2Header(0x1, 0x21, ipv4), wantErr: true},
+		{name: "truncated TLV", input: v2Header(0x1, 0x11, append(ipv4, 0x01, 0x00)), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(bufio.NewReader(bytes.NewReader(tt.input)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Command != tt.want.Command || got.Protocol != tt.want.Protocol ||
+				got.Source != tt.want.Source || got.Destination != tt.want.Destination {
+				t.Errorf("Read() = %+v, want %+v", got, tt.want)
+			}
+			if len(got.TLVs) != len(tt.want.TLVs) {
+				t.Fatalf("Read() TLVs = %v, want %v", got.TLVs, tt.want.TLVs)
+			}
+			for i := range got.TLVs {
+				if got.TLVs[i].Type != tt.want.TLVs[i].Type || !bytes.Equal(got.TLVs[i].Value, tt.want.TLVs[i].Value) {
+					t.Errorf("Read() TLV[%d] = %v, want %v", i, got.TLVs[i], tt.want.TLVs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRead_NoHeader(t *testing.T) {
+	for _, input := range []string{"GET / HTTP/1.1\r\n", "POST / HTTP/1.1\r\n", "\r\n\r\nnot a signature"} {
+		br := bufio.NewReader(strings.NewReader(input))
+		if _, err := Read(br); !errors.Is(err, ErrNoHeader) {
+			t.Errorf("Read(%q) error = %v, want ErrNoHeader", input, err)
+		}
+		if rest, _ := io.ReadAll(br); string(rest) != input {
+			t.Errorf("Read(%q) consumed input, left %q", input, rest)
+		}
+	}
+}
+
+func TestTLV_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		tlv  TLV
+		want string
+	}{
+		{TLV{0x02, []byte("example.com")}, `{"type":2,"name":"authority","value":"example.com","hex":"6578616d706c652e636f6d"}`},
+		{TLV{0xEA, []byte{0x01, 0xff}}, `{"type":234,"name":"aws","hex":"01ff"}`},
+		{TLV{0x99, nil}, `{"type":153,"name":"0x99","hex":""}`},
+	}
+
+	for _, tt := range tests {
+		got, err := json.Marshal(tt.tlv)
+		if err != nil {
+			t.Fatalf("MarshalJSON() error = %v", err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("MarshalJSON() = %s, want %s", got, tt.want)
+		}
+	}
+}
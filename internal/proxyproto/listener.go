@@ -0,0 +1,160 @@
+package proxyproto
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultHeaderTimeout bounds how long a connection may take to send its
+// PROXY header.
+const DefaultHeaderTimeout = 5 * time.Second
+
+// Listener decodes PROXY headers on connections accepted from trusted
+// sources. Connections from other sources are passed through untouched, so a
+// header they send is treated as ordinary data.
+type Listener struct {
+	net.Listener
+	// Trusted lists the networks allowed to send PROXY headers. An empty list
+	// trusts no source, so no client can forge its address by default.
+	Trusted       []*net.IPNet
+	HeaderTimeout time.Duration
+}
+
+func NewListener(ln net.Listener, trusted []*net.IPNet) *Listener {
+	return &Listener{Listener: ln, Trusted: trusted, HeaderTimeout: DefaultHeaderTimeout}
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.trusts(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &Conn{Conn: conn, br: bufio.NewReader(conn), timeout: l.HeaderTimeout}, nil
+}
+
+func (l *Listener) trusts(addr net.Addr) bool {
+	if len(l.Trusted) == 0 {
+		return false
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		// Unix socket peers are local and always trusted
+		return true
+	}
+	for _, n := range l.Trusted {
+		if n.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn reads the PROXY header lazily, on the first Read or RemoteAddr call,
+// so a slow client never blocks Accept.
+type Conn struct {
+	net.Conn
+	br      *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	header *Header
+	err    error
+}
+
+func (c *Conn) readHeader() {
+	c.once.Do(func() {
+		if c.timeout > 0 {
+			c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+			defer c.Conn.SetReadDeadline(time.Time{})
+		}
+
+		c.header, c.err = Read(c.br)
+		if errors.Is(c.err, ErrNoHeader) {
+			c.err = nil
+		} else if c.err != nil {
+			log.Printf("PROXY header from %s rejected: %v", c.Conn.RemoteAddr(), c.err)
+		}
+	})
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	if c.readHeader(); c.err != nil {
+		return 0, c.err
+	}
+	return c.br.Read(p)
+}
+
+// RemoteAddr reports the client address carried by the PROXY header, falling
+// back to the peer's address.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.header != nil && c.header.SourceAddr() != nil {
+		return c.header.SourceAddr()
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// Header returns the decoded header, or nil when none was sent.
+func (c *Conn) Header() *Header {
+	c.readHeader()
+	return c.header
+}
+
+func (c *Conn) NetConn() net.Conn {
+	return c.Conn
+}
+
+type contextKey struct{}
+
+// ConnContext is an http.Server ConnContext hook that remembers the
+// connection so handlers can retrieve its PROXY header.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the PROXY header of the connection serving ctx, looking
+// through wrappers such as *tls.Conn.
+func FromContext(ctx context.Context) *Header {
+	c, _ := ctx.Value(contextKey{}).(net.Conn)
+	for c != nil {
+		if pc, ok := c.(*Conn); ok {
+			return pc.Header()
+		}
+		wrapper, ok := c.(interface{ NetConn() net.Conn })
+		if !ok {
+			return nil
+		}
+		c = wrapper.NetConn()
+	}
+	return nil
+}
+
+// ParseCIDRs parses trusted networks. Bare IP addresses are accepted as
+// single-host networks.
+func ParseCIDRs(list []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range list {
+		if ip := net.ParseIP(s); ip != nil {
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
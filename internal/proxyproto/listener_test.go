@@ -0,0 +1,177 @@
+package proxyproto
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func acceptOne(t *testing.T, ln *Listener, send string) net.Conn {
+	t.Helper()
+
+	go func() {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			return
+		}
+		conn.Write([]byte(send))
+		conn.Close()
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func listen(t *testing.T, trusted []string) *Listener {
+	t.Helper()
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	nets, err := ParseCIDRs(trusted)
+	if err != nil {
+		t.Fatalf("ParseCIDRs() error = %v", err)
+	}
+	ln := NewListener(inner, nets)
+	t.Cleanup(func() { ln.Close() })
+	return ln
+}
+
+func TestListener_Trusted(t *testing.T) {
+	ln := listen(t, []string{"127.0.0.0/8"})
+	conn := acceptOne(t, ln, "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nhello")
+
+	if got := conn.RemoteAddr().String(); got != "192.0.2.1:56324" {
+		t.Errorf("RemoteAddr() = %v, want 192.0.2.1:56324", got)
+	}
+
+	data, _ := io.ReadAll(conn)
+	if string(data) != "hello" {
+		t.Errorf("Read() = %q, want payload after header", data)
+	}
+
+	if h := conn.(*Conn).Header(); h == nil || h.Destination != "198.51.100.1:443" {
+		t.Errorf("Header() = %+v, want decoded header", h)
+	}
+}
+
+func TestListener_WithoutHeader(t *testing.T) {
+	ln := listen(t, []string{"127.0.0.0/8"})
+	conn := acceptOne(t, ln, "GET / HTTP/1.1\r\n\r\n")
+
+	data, _ := io.ReadAll(conn)
+	if string(data) != "GET / HTTP/1.1\r\n\r\n" {
+		t.Errorf("Read() = %q, want the request untouched", data)
+	}
+	if h := conn.(*Conn).Header(); h != nil {
+		t.Errorf("Header() = %+v, want nil", h)
+	}
+	if host, _, _ := net.SplitHostPort(conn.RemoteAddr().String()); host != "127.0.0.1" {
+		t.Errorf("RemoteAddr() = %v, want the peer address", conn.RemoteAddr())
+	}
+}
+
+func TestListener_Untrusted(t *testing.T) {
+	ln := listen(t, []string{"10.0.0.0/8"})
+	conn := acceptOne(t, ln, "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n")
+
+	if _, ok := conn.(*Conn); ok {
+		t.Fatal("Accept() decoded a header from an untrusted source")
+	}
+	data, _ := io.ReadAll(conn)
+	if string(data) != "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n" {
+		t.Errorf("Read() = %q, want the header passed through as data", data)
+	}
+}
+
+func TestListener_NoTrustedNetworks(t *testing.T) {
+	ln := listen(t, nil)
+	conn := acceptOne(t, ln, "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n")
+
+	if _, ok := conn.(*Conn); ok {
+		t.Fatal("Accept() decoded a header with no trusted networks")
+	}
+}
+
+func TestListener_MalformedHeader(t *testing.T) {
+	ln := listen(t, []string{"127.0.0.0/8"})
+	conn := acceptOne(t, ln, "PROXY TCP4 not-an-ip 198.51.100.1 1 2\r\n")
+
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("Read() error = nil, want malformed header error")
+	}
+}
+
+func TestListener_HeaderTimeout(t *testing.T) {
+	ln := listen(t, []string{"127.0.0.0/8"})
+	ln.HeaderTimeout = 50 * time.Millisecond
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+	client.Write([]byte("PROXY TCP4"))
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("Read() error = nil, want timeout on incomplete header")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Read() did not honour the header timeout")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	ln := listen(t, []string{"127.0.0.0/8"})
+	conn := acceptOne(t, ln, "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n")
+
+	if h := FromContext(ConnContext(context.Background(), conn)); h == nil || h.Source != "192.0.2.1:56324" {
+		t.Errorf("FromContext() = %+v, want decoded header", h)
+	}
+
+	// Wrapped by TLS, the header is still found
+	wrapped := tls.Server(conn, &tls.Config{})
+	if h := FromContext(ConnContext(context.Background(), wrapped)); h == nil {
+		t.Error("FromContext() through *tls.Conn = nil, want decoded header")
+	}
+
+	plain, _ := net.Pipe()
+	if h := FromContext(ConnContext(context.Background(), plain)); h != nil {
+		t.Errorf("FromContext() for plain connection = %+v, want nil", h)
+	}
+	if h := FromContext(context.Background()); h != nil {
+		t.Errorf("FromContext() without connection = %+v, want nil", h)
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	nets, err := ParseCIDRs([]string{"10.0.0.0/8", "192.0.2.7", "2001:db8::/32", "::1"})
+	if err != nil {
+		t.Fatalf("ParseCIDRs() error = %v", err)
+	}
+	if len(nets) != 4 {
+		t.Fatalf("ParseCIDRs() = %d networks, want 4", len(nets))
+	}
+	if !nets[1].Contains(net.ParseIP("192.0.2.7")) || nets[1].Contains(net.ParseIP("192.0.2.8")) {
+		t.Errorf("ParseCIDRs() single host network = %v, want 192.0.2.7/32", nets[1])
+	}
+
+	if _, err := ParseCIDRs([]string{"not-a-network"}); err == nil {
+		t.Error("ParseCIDRs() error = nil, want error for invalid network")
+	}
+}
@@ -0,0 +1,208 @@
+package rawecho
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	ModeRaw  = "raw"
+	ModeLine = "line"
+)
+
+const bufferSize = 64 * 1024
+
+type Options struct {
+	// Mode is ModeRaw to echo bytes as they arrive or ModeLine to echo
+	// complete newline-terminated lines. Datagrams are always echoed whole.
+	Mode string
+	// HexDump logs every received chunk as a hex dump.
+	HexDump bool
+	// Delay is waited before each echo.
+	Delay time.Duration
+	// Limit caps the bytes echoed per TCP connection or per datagram.
+	// A TCP connection is closed once its limit is reached. Zero means no
+	// limit.
+	Limit int64
+}
+
+// ServeTCP echoes bytes on every connection accepted from ln until ctx is
+// cancelled, then closes the listener and all open connections.
+func ServeTCP(ctx context.Context, ln net.Listener, opts Options) error {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		conns = make(map[net.Conn]struct{})
+	)
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+		mu.Lock()
+		for c := range conns {
+			c.Close()
+		}
+		mu.Unlock()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			wg.Wait()
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		mu.Lock()
+		if ctx.Err() != nil {
+			mu.Unlock()
+			conn.Close()
+			continue
+		}
+		conns[conn] = struct{}{}
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				mu.Lock()
+				delete(conns, conn)
+				mu.Unlock()
+				conn.Close()
+			}()
+
+			if err := echoConn(ctx, conn, opts); err != nil && !errors.Is(err, net.ErrClosed) {
+				log.Printf("TCP echo %s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+func echoConn(ctx context.Context, conn net.Conn, opts Options) error {
+	var read func() ([]byte, error)
+	if opts.Mode == ModeLine {
+		// A line longer than the buffer is echoed in buffer-sized pieces, so
+		// a peer that never sends a newline cannot grow it without bound
+		br := bufio.NewReaderSize(conn, bufferSize)
+		read = func() ([]byte, error) {
+			data, err := br.ReadSlice('\n')
+			if errors.Is(err, bufio.ErrBufferFull) {
+				err = nil
+			}
+			return data, err
+		}
+	} else {
+		buf := make([]byte, bufferSize)
+		read = func() ([]byte, error) {
+			n, err := conn.Read(buf)
+			return buf[:n], err
+		}
+	}
+
+	var echoed int64
+	for {
+		data, err := read()
+		if len(data) > 0 {
+			if opts.HexDump {
+				log.Printf("TCP echo %s received %d bytes:\n%s", conn.RemoteAddr(), len(data), hex.Dump(data))
+			}
+
+			data, exhausted := limit(data, opts.Limit, echoed)
+			if !sleep(ctx, opts.Delay) {
+				return nil
+			}
+			if _, werr := conn.Write(data); werr != nil {
+				return werr
+			}
+			echoed += int64(len(data))
+			if exhausted {
+				return nil
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// ServeUDP echoes every datagram read from pc back to its sender until ctx is
+// cancelled.
+func ServeUDP(ctx context.Context, pc net.PacketConn, opts Options) error {
+	go func() {
+		<-ctx.Done()
+		pc.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	buf := make([]byte, bufferSize)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		data := make([]byte, n)
+		copy(data, buf[:n])
+
+		if opts.HexDump {
+			log.Printf("UDP echo %s received %d bytes:\n%s", addr, n, hex.Dump(data))
+		}
+		data, _ = limit(data, opts.Limit, 0)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !sleep(ctx, opts.Delay) {
+				return
+			}
+			if _, err := pc.WriteTo(data, addr); err != nil && ctx.Err() == nil {
+				log.Printf("UDP echo %s: %v", addr, err)
+			}
+		}()
+	}
+}
+
+// limit trims data so that no more than max bytes are echoed in total and
+// reports whether the limit has been reached.
+func limit(data []byte, max, echoed int64) ([]byte, bool) {
+	if max <= 0 {
+		return data, false
+	}
+	remaining := max - echoed
+	if int64(len(data)) >= remaining {
+		return data[:remaining], true
+	}
+	return data, false
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
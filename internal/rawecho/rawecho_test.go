@@ -0,0 +1,174 @@
+package rawecho
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func startTCP(t *testing.T, opts Options) net.Conn {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeTCP(ctx, ln, opts)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("ServeTCP() error = %v", err)
+		}
+		conn.Close()
+	})
+	return conn
+}
+
+func TestServeTCP_Raw(t *testing.T) {
+	conn := startTCP(t, Options{Mode: ModeRaw, HexDump: true})
+
+	conn.Write([]byte("hello"))
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("Failed to read echo: %v", err)
+	}
+	if string(buf) != "hello" {
+		t.Errorf("ServeTCP() echoed %q, want hello", buf)
+	}
+}
+
+func TestServeTCP_Line(t *testing.T) {
+	conn := startTCP(t, Options{Mode: ModeLine})
+	br := bufio.NewReader(conn)
+
+	conn.Write([]byte("first line\nsecond "))
+	line, err := br.ReadString('\n')
+	if err != nil || line != "first line\n" {
+		t.Fatalf("ServeTCP() echoed %q, %v, want first line", line, err)
+	}
+
+	conn.Write([]byte("line\n"))
+	line, err = br.ReadString('\n')
+	if err != nil || line != "second line\n" {
+		t.Errorf("ServeTCP() echoed %q, %v, want second line", line, err)
+	}
+}
+
+func TestServeTCP_LongLine(t *testing.T) {
+	conn := startTCP(t, Options{Mode: ModeLine})
+
+	// Never sending a newline must still echo whole buffers
+	go conn.Write(bytes.Repeat([]byte("x"), bufferSize+10))
+	buf := make([]byte, bufferSize)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("Failed to read echo of a partial line: %v", err)
+	}
+
+	conn.Write([]byte("\n"))
+	rest := make([]byte, 11)
+	if _, err := io.ReadFull(conn, rest); err != nil || string(rest) != "xxxxxxxxxx\n" {
+		t.Errorf("ServeTCP() echoed %q, %v, want the rest of the line", rest, err)
+	}
+}
+
+func TestServeTCP_Limit(t *testing.T) {
+	conn := startTCP(t, Options{Mode: ModeRaw, Limit: 3})
+
+	conn.Write([]byte("abcdef"))
+	got, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("Failed to read echo: %v", err)
+	}
+	if string(got) != "abc" {
+		t.Errorf("ServeTCP() echoed %q, want abc before closing", got)
+	}
+}
+
+func TestServeTCP_Delay(t *testing.T) {
+	conn := startTCP(t, Options{Mode: ModeRaw, Delay: 50 * time.Millisecond})
+
+	start := time.Now()
+	conn.Write([]byte("x"))
+	buf := make([]byte, 1)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("Failed to read echo: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("ServeTCP() echoed after %v, want at least 50ms", elapsed)
+	}
+}
+
+func TestServeUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeUDP(ctx, pc, Options{Limit: 4, HexDump: true})
+	}()
+
+	conn, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("datagram"))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read echo: %v", err)
+	}
+	if string(buf[:n]) != "data" {
+		t.Errorf("ServeUDP() echoed %q, want data", buf[:n])
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("ServeUDP() error = %v", err)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		max, echoed   int64
+		want          string
+		wantExhausted bool
+	}{
+		{"no limit", "abc", 0, 0, "abc", false},
+		{"under limit", "abc", 10, 0, "abc", false},
+		{"reaches limit", "abc", 3, 0, "abc", true},
+		{"trims to remaining", "abcdef", 10, 8, "ab", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, exhausted := limit([]byte(tt.data), tt.max, tt.echoed)
+			if string(got) != tt.want || exhausted != tt.wantExhausted {
+				t.Errorf("limit() = %q, %v, want %q, %v", got, exhausted, tt.want, tt.wantExhausted)
+			}
+		})
+	}
+}
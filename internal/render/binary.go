@@ -0,0 +1,62 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func encodeMsgPack(w io.Writer, v any) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+	enc := msgpack.NewEncoder(w)
+	enc.SetSortMapKeys(true)
+	enc.UseCompactInts(true)
+	return enc.Encode(plain(tree))
+}
+
+// cborMode writes map keys in the core deterministic order of RFC 8949.
+var cborMode, _ = cbor.EncOptions{Sort: cbor.SortCoreDeterministic}.EncMode()
+
+func encodeCBOR(w io.Writer, v any) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+	return cborMode.NewEncoder(w).Encode(plain(tree))
+}
+
+// plain turns a tree into the maps, slices and numbers the binary encoders
+// take. Maps don't keep field order, so both formats sort their keys instead.
+// Numbers become the smallest fitting integer, or a float64.
+func plain(v any) any {
+	switch v := v.(type) {
+	case object:
+		m := make(map[string]any, len(v))
+		for _, f := range v {
+			m[f.key] = plain(f.value)
+		}
+		return m
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = plain(item)
+		}
+		return list
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return u
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
@@ -0,0 +1,64 @@
+package render
+
+import (
+	"bufio"
+	"html"
+	"io"
+	"strings"
+)
+
+const htmlHead = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>echobox</title>
+<style>
+body { font-family: sans-serif; }
+dl { margin: 0 0 0 1em; }
+dt { font-weight: bold; }
+pre { margin: 0; }
+</style>
+</head>
+<body>
+`
+
+func encodeHTML(w io.Writer, v any) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString(htmlHead)
+	writeHTMLValue(bw, tree)
+	bw.WriteString("</body>\n</html>\n")
+	return bw.Flush()
+}
+
+func writeHTMLValue(w *bufio.Writer, v any) {
+	switch v := v.(type) {
+	case object:
+		w.WriteString("<dl>\n")
+		for _, f := range v {
+			w.WriteString("<dt>" + html.EscapeString(f.key) + "</dt>\n<dd>")
+			writeHTMLValue(w, f.value)
+			w.WriteString("</dd>\n")
+		}
+		w.WriteString("</dl>\n")
+	case []any:
+		w.WriteString("<ol>\n")
+		for _, item := range v {
+			w.WriteString("<li>")
+			writeHTMLValue(w, item)
+			w.WriteString("</li>\n")
+		}
+		w.WriteString("</ol>\n")
+	case nil:
+	default:
+		text := scalarText(v)
+		if strings.Contains(text, "\n") {
+			w.WriteString("<pre>" + html.EscapeString(text) + "</pre>")
+			return
+		}
+		w.WriteString(html.EscapeString(text))
+	}
+}
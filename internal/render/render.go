@@ -0,0 +1,258 @@
+// Package render writes a response in whichever format the client asks for
+// with ?format= or Accept.
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Format struct {
+	// Name selects the format with ?format=.
+	Name string
+	// MediaTypes are matched against Accept; the first is the Content-Type.
+	MediaTypes []string
+	encode     func(w io.Writer, v any) error
+}
+
+// TextWriter is implemented by values with a plain-text form of their own.
+// Others are written as YAML in the text format.
+type TextWriter interface {
+	WriteText(w io.Writer) error
+}
+
+var (
+	JSON    = &Format{Name: "json", MediaTypes: []string{"application/json"}, encode: encodeJSON}
+	Pretty  = &Format{Name: "pretty", MediaTypes: []string{"application/json"}, encode: encodePretty}
+	YAML    = &Format{Name: "yaml", MediaTypes: []string{"application/yaml", "application/x-yaml", "text/yaml"}, encode: encodeYAML}
+	XML     = &Format{Name: "xml", MediaTypes: []string{"application/xml", "text/xml"}, encode: encodeXML}
+	Text    = &Format{Name: "text", MediaTypes: []string{"text/plain"}, encode: encodeText}
+	HTML    = &Format{Name: "html", MediaTypes: []string{"text/html"}, encode: encodeHTML}
+	MsgPack = &Format{Name: "msgpack", MediaTypes: []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}, encode: encodeMsgPack}
+	CBOR    = &Format{Name: "cbor", MediaTypes: []string{"application/cbor"}, encode: encodeCBOR}
+)
+
+// Formats are in order of preference, which breaks ties in Accept.
+var Formats = []*Format{JSON, Pretty, Text, HTML, YAML, XML, MsgPack, CBOR}
+
+var ErrNotAcceptable = errors.New("no acceptable format")
+
+// ContentType is the Content-Type header for f.
+func (f *Format) ContentType() string {
+	if strings.HasPrefix(f.MediaTypes[0], "text/") {
+		return f.MediaTypes[0] + "; charset=utf-8"
+	}
+	return f.MediaTypes[0]
+}
+
+// Encode writes v to w in format f.
+func (f *Format) Encode(w io.Writer, v any) error {
+	return f.encode(w, v)
+}
+
+// Negotiate picks the format named by ?format=, or else the one Accept
+// prefers. Requests without Accept get JSON.
+func Negotiate(r *http.Request) (*Format, error) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		for _, f := range Formats {
+			if strings.EqualFold(f.Name, name) {
+				return f, nil
+			}
+		}
+		return nil, ErrNotAcceptable
+	}
+
+	mediaType, ok := Match(r, mediaTypes())
+	if !ok {
+		return nil, ErrNotAcceptable
+	}
+	for _, f := range Formats {
+		if slices.Contains(f.MediaTypes, mediaType) {
+			return f, nil
+		}
+	}
+	return nil, ErrNotAcceptable
+}
+
+// Match returns the media type in offers that the Accept header of r
+// prefers, with ties going to the earliest offer. Requests without Accept
+// get the first.
+func Match(r *http.Request, offers []string) (string, bool) {
+	ranges := parseAccept(strings.Join(r.Header.Values("Accept"), ","))
+	if len(ranges) == 0 {
+		return offers[0], true
+	}
+	var best string
+	var bestQ float64
+	for _, mt := range offers {
+		if q := quality(ranges, mt); q > bestQ {
+			best, bestQ = mt, q
+		}
+	}
+	return best, best != ""
+}
+
+func mediaTypes() []string {
+	var types []string
+	for _, f := range Formats {
+		types = append(types, f.MediaTypes...)
+	}
+	return types
+}
+
+// Write sends v in the negotiated format, or 406 listing the supported
+// media types when none is acceptable.
+func Write(w http.ResponseWriter, r *http.Request, v any) {
+	w.Header().Add("Vary", "Accept")
+	f, err := Negotiate(r)
+	if err != nil {
+		http.Error(w, "Not acceptable, supported types: "+strings.Join(mediaTypes(), ", "), http.StatusNotAcceptable)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := f.Encode(&buf, v); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", f.ContentType())
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+type acceptRange struct {
+	typ, sub string
+	q        float64
+}
+
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		typ, sub, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if !ok {
+			continue
+		}
+		ar := acceptRange{typ: strings.TrimSpace(typ), sub: strings.TrimSpace(sub), q: 1}
+		for _, p := range params[1:] {
+			k, v, _ := strings.Cut(p, "=")
+			if strings.TrimSpace(strings.ToLower(k)) == "q" {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && q >= 0 && q <= 1 {
+					ar.q = q
+				}
+			}
+		}
+		ranges = append(ranges, ar)
+	}
+	// The most specific matching range decides the quality
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return specificity(ranges[i]) > specificity(ranges[j])
+	})
+	return ranges
+}
+
+func specificity(ar acceptRange) int {
+	switch {
+	case ar.typ == "*":
+		return 0
+	case ar.sub == "*":
+		return 1
+	}
+	return 2
+}
+
+func quality(ranges []acceptRange, mediaType string) float64 {
+	typ, sub, _ := strings.Cut(mediaType, "/")
+	for _, ar := range ranges {
+		if (ar.typ == "*" || ar.typ == typ) && (ar.sub == "*" || ar.sub == sub) {
+			return ar.q
+		}
+	}
+	return 0
+}
+
+func encodeJSON(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func encodePretty(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func encodeText(w io.Writer, v any) error {
+	if t, ok := v.(TextWriter); ok {
+		return t.WriteText(w)
+	}
+	return encodeYAML(w, v)
+}
+
+// field is a member of an object, which keeps its fields in order.
+type field struct {
+	key   string
+	value any
+}
+
+type object []field
+
+// toTree turns v into objects, []any, string, json.Number, bool and nil by
+// way of its JSON form, so every format follows the json tags.
+func toTree(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return decodeTree(d)
+}
+
+func decodeTree(d *json.Decoder) (any, error) {
+	t, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := t.(json.Delim)
+	if !ok {
+		return t, nil
+	}
+
+	if delim == '[' {
+		list := []any{}
+		for d.More() {
+			v, err := decodeTree(d)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		_, err := d.Token()
+		return list, err
+	}
+
+	obj := object{}
+	for d.More() {
+		key, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		v, err := decodeTree(d)
+		if err != nil {
+			return nil, err
+		}
+		obj = append(obj, field{key: key.(string), value: v})
+	}
+	_, err = d.Token()
+	return obj, err
+}
@@ -0,0 +1,311 @@
+package render
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type sample struct {
+	Name    string              `json:"name"`
+	Count   int                 `json:"count"`
+	Ratio   float64             `json:"ratio"`
+	OK      bool                `json:"ok"`
+	Empty   map[string]string   `json:"empty"`
+	Headers map[string][]string `json:"headers"`
+	Items   []item              `json:"items"`
+	Skipped string              `json:"skipped,omitempty"`
+}
+
+type item struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+var testSample = sample{
+	Name:    "echo",
+	Count:   -3,
+	Ratio:   0.5,
+	OK:      true,
+	Empty:   map[string]string{},
+	Headers: map[string][]string{"Accept": {"*/*"}, "X-Two": {"a b", "yes"}},
+	Items:   []item{{Key: "k", Value: "line\nbreak"}},
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		want   *Format
+	}{
+		{"no accept", "/", "", JSON},
+		{"any", "/", "*/*", JSON},
+		{"yaml", "/", "application/yaml", YAML},
+		{"browser", "/", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", HTML},
+		{"q values", "/", "application/xml;q=0.5, application/cbor", CBOR},
+		{"wildcard subtype", "/", "text/*", Text},
+		{"excluded", "/", "*/*, application/json;q=0", Text},
+		{"case", "/", "Application/X-MsgPack", MsgPack},
+		{"query wins", "/?format=xml", "application/json", XML},
+		{"query pretty", "/?format=Pretty", "", Pretty},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			got, err := Negotiate(req)
+			if err != nil || got != tt.want {
+				t.Errorf("Negotiate() = %v, %v, want %s", got, err, tt.want.Name)
+			}
+		})
+	}
+
+	for _, tt := range []struct{ target, accept string }{
+		{"/", "image/png"},
+		{"/", "application/json;q=0"},
+		{"/?format=toml", ""},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		req.Header.Set("Accept", tt.accept)
+		if f, err := Negotiate(req); err != ErrNotAcceptable {
+			t.Errorf("Negotiate(%s, %q) = %v, %v, want ErrNotAcceptable", tt.target, tt.accept, f, err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	offers := []string{"image/png", "image/jpeg", "image/svg+xml"}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "image/png"},
+		{"image/*", "image/png"},
+		{"image/webp, image/jpeg;q=0.8, */*;q=0.1", "image/jpeg"},
+		{"image/svg+xml, image/*;q=0.5", "image/svg+xml"},
+		{"image/webp", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", tt.accept)
+		if got, ok := Match(req, offers); got != tt.want || ok != (tt.want != "") {
+			t.Errorf("Match(%q) = %q, %v, want %q", tt.accept, got, ok, tt.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/yaml")
+	w := httptest.NewRecorder()
+	Write(w, req, testSample)
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/yaml" {
+		t.Errorf("Write() = %d %q, want 200 application/yaml", w.Code, w.Header().Get("Content-Type"))
+	}
+	if w.Header().Get("Vary") != "Accept" {
+		t.Errorf("Write() Vary = %q, want Accept", w.Header().Get("Vary"))
+	}
+
+	req.Header.Set("Accept", "image/png")
+	w = httptest.NewRecorder()
+	Write(w, req, testSample)
+	if w.Code != http.StatusNotAcceptable || !strings.Contains(w.Body.String(), "application/cbor") {
+		t.Errorf("Write() = %d %q, want 406 listing the types", w.Code, w.Body.String())
+	}
+}
+
+func encode(t *testing.T, f *Format, v any) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := f.Encode(&buf, v); err != nil {
+		t.Fatalf("%s Encode() error = %v", f.Name, err)
+	}
+	return buf.String()
+}
+
+func TestYAML(t *testing.T) {
+	want := `name: echo
+count: -3
+ratio: 0.5
+ok: true
+empty: {}
+headers:
+  Accept:
+    - '*/*'
+  X-Two:
+    - a b
+    - yes
+items:
+  - key: k
+    value: |-
+      line
+      break
+`
+	if got := encode(t, YAML, testSample); got != want {
+		t.Errorf("YAML =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestXML(t *testing.T) {
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<response>
+  <name>echo</name>
+  <count>-3</count>
+  <ratio>0.5</ratio>
+  <ok>true</ok>
+  <empty/>
+  <headers>
+    <Accept>
+      <item>*/*</item>
+    </Accept>
+    <X-Two>
+      <item>a b</item>
+      <item>yes</item>
+    </X-Two>
+  </headers>
+  <items>
+    <item>
+      <key>k</key>
+      <value>line&#xA;break</value>
+    </item>
+  </items>
+</response>
+`
+	if got := encode(t, XML, testSample); got != want {
+		t.Errorf("XML =\n%s\nwant\n%s", got, want)
+	}
+
+	got := encode(t, XML, map[string]string{"a <b>": "&"})
+	if !strings.Contains(got, `<entry key="a &lt;b&gt;">&amp;</entry>`) {
+		t.Errorf("XML = %s, want invalid names as escaped entry keys", got)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	got := encode(t, HTML, map[string]string{"<script>": "a\nb"})
+	if !strings.Contains(got, "<dt>&lt;script&gt;</dt>") || !strings.Contains(got, "<pre>a\nb</pre>") {
+		t.Errorf("HTML = %s, want escaped keys and multi-line values in pre", got)
+	}
+}
+
+type wire struct{}
+
+func (wire) WriteText(w io.Writer) error {
+	_, err := io.WriteString(w, "GET / HTTP/1.1\r\n\r\n")
+	return err
+}
+
+func TestText(t *testing.T) {
+	if got := encode(t, Text, wire{}); got != "GET / HTTP/1.1\r\n\r\n" {
+		t.Errorf("Text = %q, want the value's own text", got)
+	}
+	if got := encode(t, Text, item{Key: "k", Value: "v"}); got != "key: k\nvalue: v\n" {
+		t.Errorf("Text = %q, want YAML for other values", got)
+	}
+}
+
+func TestPretty(t *testing.T) {
+	got := encode(t, Pretty, item{Key: "k"})
+	if got != "{\n  \"key\": \"k\",\n  \"value\": \"\"\n}\n" {
+		t.Errorf("Pretty = %q, want indented JSON", got)
+	}
+}
+
+func TestBinary(t *testing.T) {
+	v := struct {
+		A int      `json:"a"`
+		B []any    `json:"b"`
+		C *string  `json:"c"`
+		D float64  `json:"d"`
+		E []string `json:"e"`
+	}{A: 1, B: []any{-1, 300, true}, D: 1.5, E: []string{strings.Repeat("x", 40)}}
+
+	tests := []struct {
+		format *Format
+		want   string
+	}{
+		{MsgPack, "85" + "a161" + "01" + "a162" + "93" + "ff" + "cd012c" + "c3" + "a163" + "c0" + "a164" + "cb3ff8000000000000" + "a165" + "91" + "d928" + strings.Repeat("78", 40)},
+		{CBOR, "a5" + "6161" + "01" + "6162" + "83" + "20" + "19012c" + "f5" + "6163" + "f6" + "6164" + "fb3ff8000000000000" + "6165" + "81" + "7828" + strings.Repeat("78", 40)},
+	}
+
+	for _, tt := range tests {
+		if got := hex.EncodeToString([]byte(encode(t, tt.format, v))); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.format.Name, got, tt.want)
+		}
+	}
+}
+
+func TestBinary_RoundTrip(t *testing.T) {
+	v := map[string]any{
+		"sample": testSample,
+		"ints":   []any{0, -1, 255, -129, 65536, int64(math.MinInt64), uint64(math.MaxUint64)},
+		"floats": []float64{0.1, -2.5, 1e300},
+		"nested": map[string]any{"b": []any{}, "a": map[string]any{}, "c": nil},
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var want any
+	if err := json.Unmarshal(data, &want); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	cborDec, err := cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]any(nil))}.DecMode()
+	if err != nil {
+		t.Fatalf("DecMode() error = %v", err)
+	}
+	tests := []struct {
+		format    *Format
+		unmarshal func([]byte, any) error
+	}{
+		{MsgPack, msgpack.Unmarshal},
+		{CBOR, cborDec.Unmarshal},
+	}
+
+	for _, tt := range tests {
+		var decoded any
+		if err := tt.unmarshal([]byte(encode(t, tt.format, v)), &decoded); err != nil {
+			t.Fatalf("%s decode error = %v", tt.format.Name, err)
+		}
+		// Compare by way of JSON, which erases the integer widths
+		data, err := json.Marshal(decoded)
+		if err != nil {
+			t.Fatalf("%s Marshal() error = %v", tt.format.Name, err)
+		}
+		var got any
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s Unmarshal() error = %v", tt.format.Name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s round trip = %v, want %v", tt.format.Name, got, want)
+		}
+	}
+}
+
+func TestToTree_KeepsOrder(t *testing.T) {
+	tree, err := toTree(json.RawMessage(`{"z":1,"a":{"y":[],"b":null}}`))
+	if err != nil {
+		t.Fatalf("toTree() error = %v", err)
+	}
+	obj := tree.(object)
+	if obj[0].key != "z" || obj[1].key != "a" || obj[1].value.(object)[0].key != "y" {
+		t.Errorf("toTree() = %v, want fields in source order", tree)
+	}
+}
@@ -0,0 +1,78 @@
+package render
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// xmlName matches keys usable as element names. Others, such as header
+// values with spaces, become <entry key="...">.
+var xmlName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9._-]*$`)
+
+func encodeXML(w io.Writer, v any) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	writeXMLElement(bw, "response", tree, 0)
+	return bw.Flush()
+}
+
+func writeXMLElement(w *bufio.Writer, name string, v any, indent int) {
+	pad := strings.Repeat("  ", indent)
+	open, end := name, name
+	if !xmlName.MatchString(name) || strings.HasPrefix(strings.ToLower(name), "xml") {
+		var key strings.Builder
+		xml.EscapeText(&key, []byte(name))
+		open, end = `entry key="`+key.String()+`"`, "entry"
+	}
+
+	switch v := v.(type) {
+	case object:
+		if len(v) == 0 {
+			w.WriteString(pad + "<" + open + "/>\n")
+			return
+		}
+		w.WriteString(pad + "<" + open + ">\n")
+		for _, f := range v {
+			writeXMLElement(w, f.key, f.value, indent+1)
+		}
+		w.WriteString(pad + "</" + end + ">\n")
+	case []any:
+		if len(v) == 0 {
+			w.WriteString(pad + "<" + open + "/>\n")
+			return
+		}
+		w.WriteString(pad + "<" + open + ">\n")
+		for _, item := range v {
+			writeXMLElement(w, "item", item, indent+1)
+		}
+		w.WriteString(pad + "</" + end + ">\n")
+	case nil:
+		w.WriteString(pad + "<" + open + "/>\n")
+	default:
+		w.WriteString(pad + "<" + open + ">")
+		xml.EscapeText(w, []byte(scalarText(v)))
+		w.WriteString("</" + end + ">\n")
+	}
+}
+
+// scalarText is the text of a string, number or bool leaf.
+func scalarText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
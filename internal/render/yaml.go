@@ -0,0 +1,62 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+func encodeYAML(w io.Writer, v any) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(yamlNode(tree)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// yamlNode builds the node for v, so objects keep their field order. Empty
+// collections are written in flow style, as {} and [].
+func yamlNode(v any) *yaml.Node {
+	switch v := v.(type) {
+	case object:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		for _, f := range v {
+			n.Content = append(n.Content, yamlNode(f.key), yamlNode(f.value))
+		}
+		if len(v) == 0 {
+			n.Style = yaml.FlowStyle
+		}
+		return n
+	case []any:
+		n := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range v {
+			n.Content = append(n.Content, yamlNode(item))
+		}
+		if len(v) == 0 {
+			n.Style = yaml.FlowStyle
+		}
+		return n
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(v.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v.String()}
+	case bool:
+		n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "false"}
+		if v {
+			n.Value = "true"
+		}
+		return n
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+}
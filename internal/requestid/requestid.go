@@ -0,0 +1,57 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// Header carries the request ID in both directions.
+const Header = "X-Request-Id"
+
+// maxLength bounds incoming IDs so a client cannot fill logs with them.
+const maxLength = 200
+
+type contextKey struct{}
+
+// Middleware gives every request an ID, keeping a well-formed incoming
+// X-Request-Id, and returns it in the response header. The request headers
+// are left as the client sent them; handlers read the ID with FromContext.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !valid(id) {
+			id = New()
+		}
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, id)))
+	})
+}
+
+// FromContext returns the ID assigned by Middleware, or "" outside it.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// New returns a random 128-bit ID in hex.
+func New() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// valid accepts printable ASCII without spaces, which is safe to log and
+// echo back in a header.
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
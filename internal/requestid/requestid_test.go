@@ -0,0 +1,59 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"generated", "", false},
+		{"kept", "abc-123", true},
+		{"too long", strings.Repeat("a", maxLength+1), false},
+		{"spaces", "two words", false},
+		{"control characters", "id\x01", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen, header string
+			handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = FromContext(r.Context())
+				header = r.Header.Get(Header)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(Header, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			got := w.Header().Get(Header)
+			if got == "" || got != seen {
+				t.Errorf("response %s = %q, context = %q, want the same non-empty ID", Header, got, seen)
+			}
+			if tt.keep && got != tt.incoming {
+				t.Errorf("Middleware() ID = %q, want incoming %q", got, tt.incoming)
+			}
+			if !tt.keep && (got == tt.incoming || len(got) != 32) {
+				t.Errorf("Middleware() ID = %q, want a generated 32-character ID", got)
+			}
+			if header != tt.incoming {
+				t.Errorf("request %s = %q, want it left as sent (%q)", Header, header, tt.incoming)
+			}
+		})
+	}
+}
+
+func TestFromContext_Empty(t *testing.T) {
+	if got := FromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context()); got != "" {
+		t.Errorf("FromContext() = %q, want empty outside the middleware", got)
+	}
+}
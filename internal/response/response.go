@@ -0,0 +1,48 @@
+// Package response records what handlers write, for the middlewares that
+// report on responses.
+package response
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// Recorder records the status and size of the response. Unwrap lets
+// http.ResponseController reach the underlying writer for flushing.
+type Recorder struct {
+	http.ResponseWriter
+	// Status is the final status written, 101 for hijacked connections, or
+	// zero when nothing was written.
+	Status int
+	// Bytes counts the body bytes written.
+	Bytes int64
+}
+
+func (w *Recorder) WriteHeader(code int) {
+	if w.Status == 0 && code >= 200 {
+		w.Status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *Recorder) Write(b []byte) (int, error) {
+	if w.Status == 0 {
+		w.Status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.Bytes += int64(n)
+	return n, err
+}
+
+func (w *Recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && w.Status == 0 {
+		w.Status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (w *Recorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
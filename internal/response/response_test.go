@@ -0,0 +1,61 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	tests := []struct {
+		name       string
+		write      func(w http.ResponseWriter)
+		wantStatus int
+		wantBytes  int64
+	}{
+		{"nothing", func(w http.ResponseWriter) {}, 0, 0},
+		{"implicit", func(w http.ResponseWriter) { w.Write([]byte("hello")) }, http.StatusOK, 5},
+		{"explicit", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte("hi"))
+		}, http.StatusTeapot, 2},
+		{"informational first", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusEarlyHints)
+			w.WriteHeader(http.StatusNotFound)
+		}, http.StatusNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := &Recorder{ResponseWriter: httptest.NewRecorder()}
+			tt.write(rw)
+			if rw.Status != tt.wantStatus || rw.Bytes != tt.wantBytes {
+				t.Errorf("Recorder = %d, %d bytes, want %d, %d bytes", rw.Status, rw.Bytes, tt.wantStatus, tt.wantBytes)
+			}
+		})
+	}
+}
+
+func TestRecorder_Flush(t *testing.T) {
+	w := httptest.NewRecorder()
+	rw := &Recorder{ResponseWriter: w}
+
+	rw.Write([]byte("x"))
+	if err := http.NewResponseController(rw).Flush(); err != nil {
+		t.Errorf("Flush() through Recorder error = %v", err)
+	}
+	if !w.Flushed {
+		t.Error("Flush() did not reach the underlying writer")
+	}
+}
+
+func TestRecorder_Hijack(t *testing.T) {
+	// The recorder cannot be hijacked, so neither can what wraps it
+	rw := &Recorder{ResponseWriter: httptest.NewRecorder()}
+	if _, _, err := rw.Hijack(); err == nil {
+		t.Error("Hijack() error = nil, want the underlying writer's error")
+	}
+	if rw.Status != 0 {
+		t.Errorf("Hijack() status = %d, want 0 after a failed hijack", rw.Status)
+	}
+}
@@ -1,20 +1,358 @@
 package router
 
 import (
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"github.com/Elagoht/echobox/internal/handler"
+	"github.com/Elagoht/echobox/internal/health"
+	"github.com/Elagoht/echobox/internal/metrics"
+	"github.com/Elagoht/echobox/internal/tracing"
+	"golang.org/x/net/http/httpguts"
 	"net/http"
+	"time"
 )
 
-func New() *http.ServeMux {
+const (
+	HealthPath  = "/_health"
+	ReadyPath   = "/_ready"
+	VersionPath = "/_version"
+)
+
+// ProbePaths are left out of the access log.
+var ProbePaths = []string{HealthPath, ReadyPath, VersionPath}
+
+type Option func(*options)
+
+type options struct {
+	caPEM        []byte
+	maxBodyBytes int64
+	metrics      bool
+	health       *health.State
+	spans        *tracing.Exporter
+	admin        bool
+	adminToken   string
+	echo         handler.EchoOptions
+	upload       handler.UploadOptions
+	routes       []Route
+}
+
+// Route serves a fixed response at Path, a net/http pattern such as
+// /users/{id}, for Methods, or the standard methods when empty.
+type Route struct {
+	Path    string
+	Methods []string
+	Status  int
+	Headers map[string]string
+	Body    string
+}
+
+func (r Route) methods() []string {
+	if len(r.Methods) == 0 {
+		return handler.StandardMethods
+	}
+	return r.Methods
+}
+
+// patterns lists what New registers for r: a pattern per method plus the
+// bare path, or only the bare path for /, which replaces the catch-all.
+func (r Route) patterns() []string {
+	if r.Path == "/" {
+		return []string{r.Path}
+	}
+	var patterns []string
+	for _, method := range r.methods() {
+		patterns = append(patterns, method+" "+r.Path)
+	}
+	return append(patterns, r.Path)
+}
+
+// WithCA serves the PEM-encoded certificate authority at /ca.pem.
+func WithCA(pem []byte) Option {
+	return func(o *options) {
+		o.caPEM = pem
+	}
+}
+
+// WithClientCAs verifies client certificates shown in the echo against pool.
+func WithClientCAs(pool *x509.CertPool) Option {
+	return func(o *options) {
+		o.echo.ClientCAs = pool
+	}
+}
+
+// WithMaxBodyBytes rejects request bodies larger than n bytes with 413.
+func WithMaxBodyBytes(n int64) Option {
+	return func(o *options) {
+		o.maxBodyBytes = n
+	}
+}
+
+// WithBodyEchoLimit echoes only the first n bytes of larger bodies, along
+// with their size and digest.
+func WithBodyEchoLimit(n int64) Option {
+	return func(o *options) {
+		o.echo.BodyEchoLimit = n
+	}
+}
+
+// WithUpload rejects uploads to /upload with parts larger than maxPartBytes
+// or more than maxParts parts with 413. Zero values are unlimited.
+func WithUpload(maxPartBytes int64, maxParts int) Option {
+	return func(o *options) {
+		o.upload = handler.UploadOptions{MaxPartBytes: maxPartBytes, MaxParts: maxParts}
+	}
+}
+
+// WithRoutes serves routes in place of any built-in endpoint with the same
+// path. Probes, /metrics and the admin API cannot be replaced.
+func WithRoutes(routes ...Route) Option {
+	return func(o *options) {
+		o.routes = append(o.routes, routes...)
+	}
+}
+
+// CheckRoutes reports the routes New cannot serve: those with invalid
+// methods or patterns, and those whose patterns conflict with a built-in
+// endpoint or an earlier route. Errors name each route as routes[i].
+func CheckRoutes(routes ...Route) error {
+	var errs []error
+	for i, route := range routes {
+		seen := make(map[string]bool)
+		for _, method := range route.Methods {
+			switch {
+			// Methods are tokens, the same grammar as header names
+			case !httpguts.ValidHeaderFieldName(method):
+				errs = append(errs, fmt.Errorf("routes[%d]: invalid method %q", i, method))
+			case seen[method]:
+				errs = append(errs, fmt.Errorf("routes[%d]: duplicate method %q", i, method))
+			}
+			seen[method] = true
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	owner := make(map[string]int)
+	for i, route := range routes {
+		for _, pattern := range route.patterns() {
+			owner[pattern] = i
+		}
+	}
+
+	// Add what New would register to a throwaway mux, one pattern at a time,
+	// and pair each pattern it refuses with the one it conflicts with
+	var patterns []string
+	register(options{metrics: true, admin: true, routes: routes}, func(pattern string, _ http.Handler) {
+		patterns = append(patterns, pattern)
+	})
+	mux := http.NewServeMux()
+	var added []string
+	failed := make(map[int]bool)
+	for _, pattern := range patterns {
+		if tryHandle(mux, pattern) == nil {
+			added = append(added, pattern)
+			continue
+		}
+
+		var err error
+		i, custom := owner[pattern]
+		switch conflict := findConflict(added, pattern); {
+		case tryHandle(http.NewServeMux(), pattern) != nil:
+			err = fmt.Errorf("invalid pattern %q", pattern)
+		case conflict == pattern:
+			err = fmt.Errorf("%q is already registered", pattern)
+		case custom:
+			err = fmt.Errorf("%q conflicts with %q", pattern, conflict)
+		default:
+			// A built-in endpoint refused because of a route before it
+			i, custom = owner[conflict]
+			err = fmt.Errorf("%q conflicts with %q", conflict, pattern)
+		}
+		if custom && !failed[i] {
+			errs = append(errs, fmt.Errorf("routes[%d]: %w", i, err))
+			failed[i] = true
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// tryHandle registers pattern on mux, returning an error where ServeMux
+// would panic.
+func tryHandle(mux *http.ServeMux, pattern string) (err error) {
+	defer func() {
+		if recover() != nil {
+			err = fmt.Errorf("pattern %q refused", pattern)
+		}
+	}()
+	mux.Handle(pattern, http.NotFoundHandler())
+	return nil
+}
+
+// findConflict returns the pattern among added that pattern cannot be
+// registered next to, or "" when there is none.
+func findConflict(added []string, pattern string) string {
+	for _, other := range added {
+		mux := http.NewServeMux()
+		tryHandle(mux, other)
+		if tryHandle(mux, pattern) != nil {
+			return other
+		}
+	}
+	return ""
+}
+
+// WithHealth answers /_health and /_ready from state. Without it the router
+// is always healthy and ready.
+func WithHealth(state *health.State) Option {
+	return func(o *options) {
+		o.health = state
+	}
+}
+
+// WithAdmin serves the admin API under /_admin/, requiring token as a bearer
+// token unless it is empty.
+func WithAdmin(token string) Option {
+	return func(o *options) {
+		o.admin = true
+		o.adminToken = token
+	}
+}
+
+// WithTracing exports a server span for every request except probes and
+// metrics scrapes.
+func WithTracing(exporter *tracing.Exporter) Option {
+	return func(o *options) {
+		o.spans = exporter
+	}
+}
+
+// WithMetrics serves Prometheus metrics at /metrics.
+func WithMetrics() Option {
+	return func(o *options) {
+		o.metrics = true
+	}
+}
+
+// New builds the mux serving every endpoint. Like http.ServeMux it panics on
+// routes that CheckRoutes rejects.
+func New(opts ...Option) *http.ServeMux {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
 	mux := http.NewServeMux()
+	register(o, mux.Handle)
+	return mux
+}
 
-	// Apply method allow middleware to all handlers
-	mux.HandleFunc("/headers", handler.MethodAllow(handler.Headers))
-	mux.HandleFunc("/body", handler.MethodAllow(handler.Body))
-	mux.HandleFunc("/queries", handler.MethodAllow(handler.Queries))
+// register adds the endpoints o describes through add, which New points at
+// its mux and CheckRoutes at a list of patterns.
+func register(o options, add func(pattern string, h http.Handler)) {
+	if o.health == nil {
+		o.health = health.New()
+	}
 
-	// Catch-all handler for status codes and echo
-	mux.HandleFunc("/", handler.MethodAllow(func(w http.ResponseWriter, r *http.Request) {
+	echo := handler.NewEcho(o.echo)
+	handle := func(pattern string, h http.HandlerFunc) {
+		if o.spans != nil {
+			add(pattern, o.spans.Handler(h))
+			return
+		}
+		add(pattern, h)
+	}
+
+	// route registers h under a method pattern for each of methods, plus the
+	// bare path so that any other method gets a 405 rather than the echo.
+	// GET patterns also match HEAD, which MethodAllow turns away unless listed.
+	overridden := make(map[string]bool)
+	route := func(path string, methods []string, h http.HandlerFunc) {
+		if overridden[path] {
+			return
+		}
+		h = handler.MethodAllow(methods, h)
+		for _, method := range methods {
+			handle(method+" "+path, h)
+		}
+		handle(path, h)
+	}
+	get := []string{http.MethodGet, http.MethodHead}
+
+	// Probes are reserved so they never reach the echo
+	probes := []struct {
+		path string
+		h    http.HandlerFunc
+	}{
+		{HealthPath, handler.Health(o.health)},
+		{ReadyPath, handler.Ready(o.health)},
+		{VersionPath, handler.Version},
+	}
+	for _, p := range probes {
+		h := handler.MethodAllow(get, p.h)
+		add("GET "+p.path, h)
+		add(p.path, h)
+	}
+	if o.metrics {
+		h := handler.MethodAllow(get, metrics.Handler)
+		add("GET /metrics", h)
+		add("/metrics", h)
+	}
+	if o.admin {
+		handle("/_admin/health", handler.RequireToken(o.adminToken, handler.AdminHealth(o.health)))
+		handle("/_admin/", handler.RequireToken(o.adminToken, handler.AdminNotFound))
+	}
+
+	for _, r := range o.routes {
+		h := handler.MethodAllow(r.methods(), handler.Fixed(r.Status, r.Headers, r.Body))
+		for _, pattern := range r.patterns() {
+			handle(pattern, h)
+		}
+		overridden[r.Path] = true
+	}
+
+	body := handler.LimitBody(o.maxBodyBytes, echo)
+	route("/get", get, body)
+	route("/post", []string{http.MethodPost}, body)
+	route("/put", []string{http.MethodPut}, body)
+	route("/patch", []string{http.MethodPatch}, body)
+	route("/delete", []string{http.MethodDelete}, body)
+
+	route("/bytes/{n}", get, handler.Bytes)
+	route("/stream-bytes/{n}", get, handler.StreamBytes)
+	route("/range/{n}", get, handler.Range)
+	route("/uuid", get, handler.UUID)
+	route("/base64/{value...}", get, handler.Base64)
+	route("/json", get, handler.Sample("application/json", handler.SampleJSON))
+	route("/xml", get, handler.Sample("application/xml", handler.SampleXML))
+	route("/html", get, handler.Sample("text/html; charset=utf-8", handler.SampleHTML))
+
+	route("/image", get, handler.Image(""))
+	for _, format := range []string{"png", "jpeg", "gif", "svg"} {
+		route("/image/"+format, get, handler.Image(format))
+	}
+
+	// Cached responses last changed when the router was built
+	modified := time.Now()
+	route("/cache", get, handler.Cache(modified, echo))
+	route("/cache/{seconds}", get, handler.Cache(modified, echo))
+	route("/etag/{etag}", handler.StandardMethods, handler.LimitBody(o.maxBodyBytes, handler.ETag(echo)))
+	route("/response-headers", []string{http.MethodGet, http.MethodHead, http.MethodPost}, handler.ResponseHeaders)
+
+	route("/headers", handler.StandardMethods, handler.Headers)
+	route("/body", handler.StandardMethods, handler.LimitBody(o.maxBodyBytes, handler.Body))
+	route("/upload", []string{http.MethodPost, http.MethodPut}, handler.LimitBody(o.maxBodyBytes, handler.NewUpload(o.upload)))
+	route("/queries", handler.StandardMethods, handler.Queries)
+	route("/raw", handler.StandardMethods, handler.Raw)
+	route("/ws", get, handler.WebSocket)
+	route("/ca.pem", get, handler.CA(o.caPEM))
+
+	// Catch-all handler for status codes and echo. It cannot use method
+	// patterns: "GET /" would conflict with the bare paths above.
+	if overridden["/"] {
+		return
+	}
+	handle("/", handler.MethodAllow(handler.StandardMethods, handler.LimitBody(o.maxBodyBytes, func(w http.ResponseWriter, r *http.Request) {
 		// Check if path is a 3-digit status code
 		if handler.MatchStatusCode(r.URL.Path) {
 			handler.ServeStatusCode(w, r.URL.Path)
@@ -22,8 +360,6 @@ func New() *http.ServeMux {
 		}
 
 		// Default to echo handler
-		handler.Echo(w, r)
-	}))
-
-	return mux
+		echo(w, r)
+	})))
 }
@@ -1,10 +1,16 @@
 package router
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/Elagoht/echobox/internal/health"
+	"github.com/Elagoht/echobox/internal/tracing"
 )
 
 func TestNew(t *testing.T) {
@@ -76,6 +82,13 @@ func TestRouter_Routes(t *testing.T) {
 			wantStatus: http.StatusOK,
 			wantAllow:  "GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS",
 		},
+		{
+			name:       "GET /ws without upgrade",
+			method:     http.MethodGet,
+			path:       "/ws",
+			wantStatus: http.StatusUpgradeRequired,
+			wantAllow:  "GET, HEAD",
+		},
 		{
 			name:       "GET /randompath (echo)",
 			method:     http.MethodGet,
@@ -133,6 +146,130 @@ func TestRouter_AllowsAllMethods(t *testing.T) {
 	}
 }
 
+func TestRouter_MethodRoutes(t *testing.T) {
+	mux := New()
+
+	tests := []struct {
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{http.MethodGet, "/get", http.StatusOK, "GET, HEAD"},
+		{http.MethodHead, "/get", http.StatusOK, "GET, HEAD"},
+		{http.MethodPost, "/get", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{http.MethodPost, "/post", http.StatusOK, "POST"},
+		{http.MethodGet, "/post", http.StatusMethodNotAllowed, "POST"},
+		{http.MethodPut, "/put", http.StatusOK, "PUT"},
+		{http.MethodPatch, "/put", http.StatusMethodNotAllowed, "PUT"},
+		{http.MethodPatch, "/patch", http.StatusOK, "PATCH"},
+		{http.MethodDelete, "/delete", http.StatusOK, "DELETE"},
+		{http.MethodHead, "/delete", http.StatusMethodNotAllowed, "DELETE"},
+		{http.MethodPost, "/ws", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"PROPFIND", "/_health", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{http.MethodDelete, "/_ready", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{http.MethodPost, "/_version", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{http.MethodDelete, "/ca.pem", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"PROPFIND", "/headers", http.StatusMethodNotAllowed, "GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS"},
+		{"PROPFIND", "/anything", http.StatusMethodNotAllowed, "GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+
+	// The method-specific endpoints echo like the catch-all
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/post", strings.NewReader("hello")))
+	var resp struct {
+		Method string `json:"method"`
+		Body   string `json:"body"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Method != http.MethodPost || resp.Body != "hello" {
+		t.Errorf("POST /post = %q, want the echo", w.Body.String())
+	}
+}
+
+func TestRouter_Head(t *testing.T) {
+	mux := New()
+
+	for _, path := range []string{"/", "/get", "/headers", "/404", "/ws", "/_health", "/_version"} {
+		get := httptest.NewRecorder()
+		mux.ServeHTTP(get, httptest.NewRequest(http.MethodGet, path, nil))
+		head := httptest.NewRecorder()
+		mux.ServeHTTP(head, httptest.NewRequest(http.MethodHead, path, nil))
+
+		if head.Code != get.Code {
+			t.Errorf("HEAD %s status = %d, want %d like GET", path, head.Code, get.Code)
+		}
+		if head.Body.Len() != 0 {
+			t.Errorf("HEAD %s body = %q, want none", path, head.Body.String())
+		}
+		// The recorder does not sniff, so only declared types compare
+		if want := get.Header().Get("Content-Type"); want != "" && head.Header().Get("Content-Type") != want {
+			t.Errorf("HEAD %s Content-Type = %q, want %q like GET", path, head.Header().Get("Content-Type"), want)
+		}
+		if head.Header().Get("Content-Length") == "" {
+			t.Errorf("HEAD %s has no Content-Length, want the GET body size", path)
+		}
+	}
+}
+
+func TestRouter_Generate(t *testing.T) {
+	mux := New()
+
+	tests := []struct {
+		method     string
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{http.MethodGet, "/bytes/10", http.StatusOK, ""},
+		{http.MethodGet, "/stream-bytes/10", http.StatusOK, ""},
+		{http.MethodGet, "/range/5", http.StatusOK, "abcde"},
+		{http.MethodGet, "/base64/aGk=", http.StatusOK, "hi"},
+		{http.MethodGet, "/base64/a/b+", http.StatusOK, ""},
+		{http.MethodGet, "/uuid", http.StatusOK, ""},
+		{http.MethodGet, "/json", http.StatusOK, ""},
+		{http.MethodGet, "/xml", http.StatusOK, ""},
+		{http.MethodGet, "/html", http.StatusOK, ""},
+		{http.MethodGet, "/image", http.StatusOK, ""},
+		{http.MethodGet, "/image/svg?width=1", http.StatusOK, ""},
+		{http.MethodGet, "/cache/60", http.StatusOK, ""},
+		{http.MethodPut, "/etag/abc", http.StatusOK, ""},
+		{http.MethodPost, "/response-headers?X-A=b", http.StatusOK, `{"X-A":["b"]}` + "\n"},
+		{http.MethodPost, "/upload", http.StatusUnsupportedMediaType, ""},
+		{http.MethodGet, "/upload", http.StatusMethodNotAllowed, ""},
+		{http.MethodPost, "/cache", http.StatusMethodNotAllowed, ""},
+		{http.MethodPost, "/uuid", http.StatusMethodNotAllowed, ""},
+		{http.MethodPut, "/bytes/10", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
 func TestRouter_StatusCodeRange(t *testing.T) {
 	mux := New()
 
@@ -153,3 +290,284 @@ func TestRouter_StatusCodeRange(t *testing.T) {
 		})
 	}
 }
+
+func TestRouter_CA(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		wantStatus int
+	}{
+		{"without CA", nil, http.StatusNotFound},
+		{"with CA", []Option{WithCA([]byte("pem"))}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ca.pem", nil)
+			w := httptest.NewRecorder()
+
+			New(tt.opts...).ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Router /ca.pem status = %v, want %v", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRouter_Metrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		wantType string
+	}{
+		{"without metrics", nil, "application/json"},
+		{"with metrics", []Option{WithMetrics()}, "text/plain; version=0.0.4; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			w := httptest.NewRecorder()
+
+			New(tt.opts...).ServeHTTP(w, req)
+
+			if got := w.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Router /metrics Content-Type = %v, want %v", got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestRouter_Probes(t *testing.T) {
+	state := health.New()
+	router := New(WithHealth(state))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		ready      bool
+		wantStatus int
+		wantBody   string
+	}{
+		{"health", http.MethodGet, "/_health", true, http.StatusOK, `"status":"ok"`},
+		{"ready", http.MethodGet, "/_ready", true, http.StatusOK, `"status":"ready"`},
+		{"draining", http.MethodGet, "/_ready", false, http.StatusServiceUnavailable, `"status":"draining"`},
+		{"version", http.MethodGet, "/_version", true, http.StatusOK, `"go_version"`},
+		{"probes are never echoed", http.MethodPost, "/_health", true, http.StatusMethodNotAllowed, "Method not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state.SetReady(tt.ready)
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Router %s %s status = %v, want %v", tt.method, tt.path, w.Code, tt.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("Router %s %s body = %s, want it to contain %s", tt.method, tt.path, w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRouter_Admin(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		wantStatus int
+		wantBody   string
+	}{
+		{"without admin", nil, http.StatusOK, `"path":"/_admin/health"`},
+		{"with admin", []Option{WithAdmin("")}, http.StatusOK, `{"mode":"healthy"}`},
+		{"with token", []Option{WithAdmin("secret")}, http.StatusUnauthorized, `"error"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/_admin/health", nil)
+			w := httptest.NewRecorder()
+
+			New(tt.opts...).ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("Router /_admin/health = %d %s, want %d containing %s", w.Code, w.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRouter_AdminUnknownPath(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		header     string
+		wantStatus int
+	}{
+		{"without admin", nil, "", http.StatusOK},
+		{"with admin", []Option{WithAdmin("")}, "", http.StatusNotFound},
+		{"without token", []Option{WithAdmin("secret")}, "", http.StatusUnauthorized},
+		{"with token", []Option{WithAdmin("secret")}, "Bearer secret", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/_admin/nothing", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			New(tt.opts...).ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Router /_admin/nothing status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRouter_Tracing(t *testing.T) {
+	var names []string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []tracing.Span `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		for _, span := range req.ResourceSpans[0].ScopeSpans[0].Spans {
+			names = append(names, span.Name)
+		}
+	}))
+	defer collector.Close()
+
+	exporter := tracing.NewExporter(tracing.ExporterOptions{Endpoint: collector.URL, ServiceName: "echobox"})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- exporter.Run(ctx) }()
+
+	router := New(WithTracing(exporter), WithMetrics())
+	for _, path := range []string{"/headers", "/_health", "/metrics", "/anything"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	cancel()
+	<-done
+
+	want := []string{"GET /headers", "GET /"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Router exported spans %v, want %v without probes and metrics", names, want)
+	}
+}
+
+func TestRouter_MaxBodyBytes(t *testing.T) {
+	tests := []struct {
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"/", "small", http.StatusOK},
+		{"/", strings.Repeat("a", 17), http.StatusRequestEntityTooLarge},
+		{"/body", strings.Repeat("a", 17), http.StatusRequestEntityTooLarge},
+		{"/anything", strings.Repeat("a", 17), http.StatusRequestEntityTooLarge},
+		{"/upload", strings.Repeat("a", 17), http.StatusRequestEntityTooLarge},
+	}
+
+	router := New(WithMaxBodyBytes(16), WithBodyEchoLimit(4))
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("Router %s with %d bytes status = %v, want %v", tt.path, len(tt.body), w.Code, tt.wantStatus)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("0123456789"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `"body":"0123"`) || !strings.Contains(w.Body.String(), `"truncated":true`) {
+		t.Errorf("Router echo = %s, want the body truncated to 4 bytes", w.Body.String())
+	}
+}
+
+func TestRouter_CustomRoutes(t *testing.T) {
+	mux := New(WithRoutes(
+		Route{Path: "/users/{id}", Methods: []string{http.MethodGet}, Status: http.StatusCreated, Headers: map[string]string{"X-Stub": "yes"}, Body: "user"},
+		Route{Path: "/headers", Body: "replaced"},
+	))
+
+	tests := []struct {
+		method     string
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{http.MethodGet, "/users/1", http.StatusCreated, "user"},
+		{http.MethodPost, "/users/1", http.StatusMethodNotAllowed, ""},
+		{http.MethodPut, "/headers", http.StatusOK, "replaced"},
+		{http.MethodGet, "/_health", http.StatusOK, ""},
+		{http.MethodGet, "/queries", http.StatusOK, "{"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if w.Code != tt.wantStatus || !strings.HasPrefix(w.Body.String(), tt.wantBody) {
+				t.Errorf("Router %s %s = %d %q, want %d %q", tt.method, tt.target, w.Code, w.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	New(WithRoutes(Route{Path: "/", Status: http.StatusTeapot})).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/anything", nil))
+	if w.Code != http.StatusTeapot {
+		t.Errorf("Router with / replaced status = %d, want %d", w.Code, http.StatusTeapot)
+	}
+}
+
+func TestCheckRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		routes  []Route
+		wantErr string
+	}{
+		{"pattern", []Route{{Path: "/users/{id}"}}, ""},
+		{"replaced endpoint", []Route{{Path: "/headers", Methods: []string{"PROPFIND"}}}, ""},
+		{"catch-all", []Route{{Path: "/"}}, ""},
+		{"probe", []Route{{Path: "/_health"}}, `routes[0]: "GET /_health" is already registered`},
+		{"metrics", []Route{{Path: "/ok"}, {Path: "/metrics"}}, `routes[1]: "GET /metrics" is already registered`},
+		{"built-in conflict", []Route{{Path: "/bytes/{m}", Methods: []string{http.MethodGet}}}, `routes[0]: "GET /bytes/{m}" conflicts with "GET /bytes/{n}"`},
+		{"admin conflict", []Route{{Path: "/{x}/5", Methods: []string{http.MethodGet}}}, `routes[0]: "GET /{x}/5" conflicts with "/_admin/"`},
+		{"earlier route", []Route{{Path: "/v1/a/{x}"}, {Path: "/v1/{y}/b"}}, `routes[1]: "GET /v1/{y}/b" conflicts with "GET /v1/a/{x}"`},
+		{"invalid pattern", []Route{{Path: "/a/{"}}, `routes[0]: invalid pattern "GET /a/{"`},
+		{"invalid method", []Route{{Path: "/a", Methods: []string{"GET /b"}}}, `routes[0]: invalid method "GET /b"`},
+		{"duplicate method", []Route{{Path: "/a", Methods: []string{"PUT", "PUT"}}}, `routes[0]: duplicate method "PUT"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckRoutes(tt.routes...)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckRoutes() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("CheckRoutes() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TraceParent is a parsed W3C traceparent header.
+type TraceParent struct {
+	Version  string `json:"version"`
+	TraceID  string `json:"trace_id"`
+	ParentID string `json:"parent_id"`
+	Flags    string `json:"flags"`
+	Sampled  bool   `json:"sampled"`
+}
+
+// Member is one key=value entry of a W3C tracestate header, kept in order.
+type Member struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// B3 holds Zipkin B3 propagation headers, from either the single b3 header
+// or the X-B3-* headers.
+type B3 struct {
+	Format       string `json:"format"`
+	TraceID      string `json:"trace_id,omitempty"`
+	SpanID       string `json:"span_id,omitempty"`
+	ParentSpanID string `json:"parent_span_id,omitempty"`
+	Sampled      *bool  `json:"sampled,omitempty"`
+	Debug        bool   `json:"debug,omitempty"`
+}
+
+// Info is the trace context a request carried. Errors lists headers that
+// were present but malformed, which is usually what a propagation test is
+// looking for.
+type Info struct {
+	TraceParent *TraceParent `json:"traceparent,omitempty"`
+	TraceState  []Member     `json:"tracestate,omitempty"`
+	B3          *B3          `json:"b3,omitempty"`
+	Errors      []string     `json:"errors,omitempty"`
+}
+
+// Parse reads the W3C and B3 trace headers, returning nil when there are none.
+func Parse(h http.Header) *Info {
+	info := &Info{}
+	found := false
+
+	if v := h.Get("Traceparent"); v != "" {
+		found = true
+		tp, err := ParseTraceParent(v)
+		if err != nil {
+			info.Errors = append(info.Errors, "traceparent: "+err.Error())
+		}
+		info.TraceParent = tp
+	}
+	if values := h.Values("Tracestate"); len(values) > 0 {
+		found = true
+		members, err := parseTraceState(strings.Join(values, ","))
+		if err != nil {
+			info.Errors = append(info.Errors, "tracestate: "+err.Error())
+		}
+		info.TraceState = members
+	}
+
+	if v := h.Get("B3"); v != "" {
+		found = true
+		b3, err := parseB3Single(v)
+		if err != nil {
+			info.Errors = append(info.Errors, "b3: "+err.Error())
+		}
+		info.B3 = b3
+	} else if b3, errs := parseB3Multi(h); b3 != nil {
+		found = true
+		info.B3 = b3
+		info.Errors = append(info.Errors, errs...)
+	}
+
+	if !found {
+		return nil
+	}
+	return info
+}
+
+// ParseTraceParent parses version-traceid-parentid-flags. Versions after 00
+// may append fields, which are ignored.
+func ParseTraceParent(v string) (*TraceParent, error) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 {
+		return nil, fmt.Errorf("expected 4 dash-separated fields, got %d", len(parts))
+	}
+	tp := &TraceParent{Version: parts[0], TraceID: parts[1], ParentID: parts[2], Flags: parts[3]}
+
+	switch {
+	case !isHex(tp.Version, 2) || tp.Version == "ff":
+		return nil, fmt.Errorf("invalid version %q", tp.Version)
+	case tp.Version == "00" && len(parts) != 4:
+		return nil, fmt.Errorf("version 00 takes 4 fields, got %d", len(parts))
+	case !isHex(tp.TraceID, 32) || isZero(tp.TraceID):
+		return nil, fmt.Errorf("invalid trace-id %q", tp.TraceID)
+	case !isHex(tp.ParentID, 16) || isZero(tp.ParentID):
+		return nil, fmt.Errorf("invalid parent-id %q", tp.ParentID)
+	case !isHex(tp.Flags, 2):
+		return nil, fmt.Errorf("invalid flags %q", tp.Flags)
+	}
+	tp.Sampled = fromHex(tp.Flags[1])&1 == 1
+	return tp, nil
+}
+
+// String formats tp as a traceparent header value.
+func (tp *TraceParent) String() string {
+	return tp.Version + "-" + tp.TraceID + "-" + tp.ParentID + "-" + tp.Flags
+}
+
+func parseTraceState(v string) ([]Member, error) {
+	var members []Member
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || key == "" || value == "" {
+			return members, fmt.Errorf("invalid member %q", entry)
+		}
+		members = append(members, Member{Key: key, Value: value})
+	}
+	if len(members) > 32 {
+		return members, fmt.Errorf("%d members, at most 32 allowed", len(members))
+	}
+	return members, nil
+}
+
+// parseB3Single parses {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId},
+// where the last two are optional, or a lone sampling state.
+func parseB3Single(v string) (*B3, error) {
+	b3 := &B3{Format: "single"}
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) == 1 {
+		return b3, b3.setSampling(parts[0])
+	}
+	if len(parts) > 4 {
+		return b3, fmt.Errorf("expected at most 4 dash-separated fields, got %d", len(parts))
+	}
+
+	b3.TraceID, b3.SpanID = parts[0], parts[1]
+	if err := b3.checkIDs(); err != nil {
+		return b3, err
+	}
+	if len(parts) > 2 {
+		if err := b3.setSampling(parts[2]); err != nil {
+			return b3, err
+		}
+	}
+	if len(parts) > 3 {
+		b3.ParentSpanID = parts[3]
+		if !isHex(b3.ParentSpanID, 16) {
+			return b3, fmt.Errorf("invalid parent span id %q", b3.ParentSpanID)
+		}
+	}
+	return b3, nil
+}
+
+func parseB3Multi(h http.Header) (*B3, []string) {
+	b3 := &B3{
+		Format:       "multi",
+		TraceID:      h.Get("X-B3-TraceId"),
+		SpanID:       h.Get("X-B3-SpanId"),
+		ParentSpanID: h.Get("X-B3-ParentSpanId"),
+	}
+	sampled, flags := h.Get("X-B3-Sampled"), h.Get("X-B3-Flags")
+	if b3.TraceID == "" && b3.SpanID == "" && b3.ParentSpanID == "" && sampled == "" && flags == "" {
+		return nil, nil
+	}
+
+	var errs []string
+	if b3.TraceID != "" || b3.SpanID != "" {
+		if err := b3.checkIDs(); err != nil {
+			errs = append(errs, "x-b3: "+err.Error())
+		}
+	}
+	if b3.ParentSpanID != "" && !isHex(b3.ParentSpanID, 16) {
+		errs = append(errs, fmt.Sprintf("x-b3-parentspanid: invalid span id %q", b3.ParentSpanID))
+	}
+	switch sampled {
+	case "":
+	case "1", "true":
+		b3.Sampled = boolPtr(true)
+	case "0", "false":
+		b3.Sampled = boolPtr(false)
+	default:
+		errs = append(errs, fmt.Sprintf("x-b3-sampled: invalid value %q", sampled))
+	}
+	switch flags {
+	case "":
+	case "1":
+		b3.Debug = true
+	default:
+		errs = append(errs, fmt.Sprintf("x-b3-flags: invalid value %q", flags))
+	}
+	return b3, errs
+}
+
+func (b3 *B3) checkIDs() error {
+	if !isHex(b3.TraceID, 16) && !isHex(b3.TraceID, 32) {
+		return fmt.Errorf("invalid trace id %q", b3.TraceID)
+	}
+	if !isHex(b3.SpanID, 16) {
+		return fmt.Errorf("invalid span id %q", b3.SpanID)
+	}
+	return nil
+}
+
+func (b3 *B3) setSampling(s string) error {
+	switch s {
+	case "1":
+		b3.Sampled = boolPtr(true)
+	case "0":
+		b3.Sampled = boolPtr(false)
+	case "d":
+		b3.Sampled = boolPtr(true)
+		b3.Debug = true
+	default:
+		return fmt.Errorf("invalid sampling state %q", s)
+	}
+	return nil
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// isHex reports whether s is n lowercase hex digits.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+func fromHex(c byte) byte {
+	if c >= 'a' {
+		return c - 'a' + 10
+	}
+	return c - '0'
+}
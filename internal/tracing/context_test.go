@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    *TraceParent
+		wantErr string
+	}{
+		{
+			value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want:  &TraceParent{Version: "00", TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", ParentID: "00f067aa0ba902b7", Flags: "01", Sampled: true},
+		},
+		{
+			value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			want:  &TraceParent{Version: "00", TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", ParentID: "00f067aa0ba902b7", Flags: "00"},
+		},
+		{
+			value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03-future",
+			want:  &TraceParent{Version: "01", TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", ParentID: "00f067aa0ba902b7", Flags: "03", Sampled: true},
+		},
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", wantErr: "4 dash-separated fields"},
+		{value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: "invalid version"},
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", wantErr: "version 00 takes 4 fields"},
+		{value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", wantErr: "invalid trace-id"},
+		{value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", wantErr: "invalid trace-id"},
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", wantErr: "invalid parent-id"},
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-x1", wantErr: "invalid flags"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTraceParent(tt.value)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseTraceParent(%q) error = %v, want %q", tt.value, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTraceParent(%q) error = %v", tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTraceParent(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	sampled, unsampled := true, false
+
+	tests := []struct {
+		name    string
+		headers map[string][]string
+		want    *Info
+	}{
+		{name: "no trace headers", headers: map[string][]string{"Accept": {"*/*"}}},
+		{
+			name: "w3c",
+			headers: map[string][]string{
+				"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+				"Tracestate":  {"rojo=00f067aa0ba902b7", "congo=t61rcWkgMzE, ,vendor@tenant=x"},
+			},
+			want: &Info{
+				TraceParent: &TraceParent{Version: "00", TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", ParentID: "00f067aa0ba902b7", Flags: "01", Sampled: true},
+				TraceState:  []Member{{"rojo", "00f067aa0ba902b7"}, {"congo", "t61rcWkgMzE"}, {"vendor@tenant", "x"}},
+			},
+		},
+		{
+			name:    "invalid tracestate",
+			headers: map[string][]string{"Tracestate": {"rojo=1,broken"}},
+			want:    &Info{TraceState: []Member{{"rojo", "1"}}, Errors: []string{`tracestate: invalid member "broken"`}},
+		},
+		{
+			name:    "b3 single",
+			headers: map[string][]string{"B3": {"80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90"}},
+			want: &Info{B3: &B3{
+				Format: "single", TraceID: "80f198ee56343ba864fe8b2a57d3eff7", SpanID: "e457b5a2e4d86bd1",
+				ParentSpanID: "05e3ac9a4f6e3b90", Sampled: &sampled,
+			}},
+		},
+		{
+			name:    "b3 single debug only",
+			headers: map[string][]string{"B3": {"d"}},
+			want:    &Info{B3: &B3{Format: "single", Sampled: &sampled, Debug: true}},
+		},
+		{
+			name:    "b3 single invalid span",
+			headers: map[string][]string{"B3": {"80f198ee56343ba8-e457"}},
+			want: &Info{
+				B3:     &B3{Format: "single", TraceID: "80f198ee56343ba8", SpanID: "e457"},
+				Errors: []string{`b3: invalid span id "e457"`},
+			},
+		},
+		{
+			name: "b3 multi",
+			headers: map[string][]string{
+				"X-B3-Traceid": {"463ac35c9f6413ad"},
+				"X-B3-Spanid":  {"a2fb4a1d1a96d312"},
+				"X-B3-Sampled": {"0"},
+			},
+			want: &Info{B3: &B3{Format: "multi", TraceID: "463ac35c9f6413ad", SpanID: "a2fb4a1d1a96d312", Sampled: &unsampled}},
+		},
+		{
+			name: "b3 multi invalid",
+			headers: map[string][]string{
+				"X-B3-Traceid": {"463ac35c9f6413ad"},
+				"X-B3-Sampled": {"maybe"},
+			},
+			want: &Info{
+				B3:     &B3{Format: "multi", TraceID: "463ac35c9f6413ad"},
+				Errors: []string{`x-b3: invalid span id ""`, `x-b3-sampled: invalid value "maybe"`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(http.Header(tt.headers))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
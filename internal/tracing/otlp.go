@@ -0,0 +1,196 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// OTLP/HTTP JSON encoding of the trace export request. Trace and span IDs
+// are hex strings and 64-bit integers are decimal strings, as the protobuf
+// JSON mapping requires.
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []Attribute `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []Span `json:"spans"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+const (
+	SpanKindServer = 2
+
+	StatusUnset = 0
+	StatusError = 2
+)
+
+type Span struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	TraceState        string      `json:"traceState,omitempty"`
+	ParentSpanID      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []Attribute `json:"attributes,omitempty"`
+	Status            Status      `json:"status"`
+}
+
+type Status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type Attribute struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+type AnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: AnyValue{StringValue: &value}}
+}
+
+func Int(key string, value int64) Attribute {
+	s := strconv.FormatInt(value, 10)
+	return Attribute{Key: key, Value: AnyValue{IntValue: &s}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+const (
+	defaultQueueSize = 2048
+	defaultBatchSize = 512
+	defaultInterval  = 2 * time.Second
+	exportTimeout    = 10 * time.Second
+)
+
+type ExporterOptions struct {
+	// Endpoint is the URL spans are posted to, such as
+	// http://localhost:4318/v1/traces.
+	Endpoint       string
+	ServiceName    string
+	ServiceVersion string
+	// Interval is how often queued spans are sent. Defaults to 2s.
+	Interval time.Duration
+}
+
+// Exporter queues finished spans and posts them in batches from Run. Spans
+// arriving while the queue is full are dropped rather than slowing requests.
+type Exporter struct {
+	opts   ExporterOptions
+	client *http.Client
+	queue  chan Span
+}
+
+func NewExporter(opts ExporterOptions) *Exporter {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+	return &Exporter{
+		opts:   opts,
+		client: &http.Client{Timeout: exportTimeout},
+		queue:  make(chan Span, defaultQueueSize),
+	}
+}
+
+// Export queues s, reporting false when it had to be dropped.
+func (e *Exporter) Export(s Span) bool {
+	select {
+	case e.queue <- s:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run sends queued spans until ctx is cancelled, then sends what is left.
+func (e *Exporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.opts.Interval)
+	defer ticker.Stop()
+
+	var batch []Span
+	for {
+		select {
+		case s := <-e.queue:
+			if batch = append(batch, s); len(batch) >= defaultBatchSize {
+				e.send(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				e.send(batch)
+				batch = nil
+			}
+		case <-ctx.Done():
+			// Run is the only reader, so the queue can be drained by length
+			for len(e.queue) > 0 {
+				batch = append(batch, <-e.queue)
+			}
+			if len(batch) > 0 {
+				e.send(batch)
+			}
+			return nil
+		}
+	}
+}
+
+func (e *Exporter) send(spans []Span) {
+	if err := e.post(spans); err != nil {
+		log.Printf("Exporting %d spans: %v", len(spans), err)
+	}
+}
+
+func (e *Exporter) post(spans []Span) error {
+	attrs := []Attribute{String("service.name", e.opts.ServiceName)}
+	if e.opts.ServiceVersion != "" {
+		attrs = append(attrs, String("service.version", e.opts.ServiceVersion))
+	}
+	body, err := json.Marshal(exportRequest{ResourceSpans: []resourceSpans{{
+		Resource: resource{Attributes: attrs},
+		ScopeSpans: []scopeSpans{{
+			Scope: scope{Name: "echobox", Version: e.opts.ServiceVersion},
+			Spans: spans,
+		}},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.opts.Endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector is a stand-in OTLP/HTTP endpoint that keeps every span it gets.
+type collector struct {
+	mu       sync.Mutex
+	requests []exportRequest
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req exportRequest
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.requests = append(c.requests, req)
+	c.mu.Unlock()
+}
+
+func (c *collector) spans() []Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var spans []Span
+	for _, req := range c.requests {
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+func attr(span Span, key string) string {
+	for _, a := range span.Attributes {
+		if a.Key != key {
+			continue
+		}
+		if a.Value.StringValue != nil {
+			return *a.Value.StringValue
+		}
+		if a.Value.IntValue != nil {
+			return *a.Value.IntValue
+		}
+	}
+	return ""
+}
+
+func TestExporter(t *testing.T) {
+	c := &collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	e := NewExporter(ExporterOptions{Endpoint: server.URL + "/v1/traces", ServiceName: "echobox-test", Interval: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- e.Run(ctx) }()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "broken" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		io.WriteString(w, "ok")
+	})
+	handler := e.Handler(mux)
+
+	requests := []map[string]string{
+		{"path": "/items/1", "Traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "Tracestate": "rojo=1"},
+		{"path": "/items/2", "Traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+		{"path": "/items/3", "X-B3-Traceid": "463ac35c9f6413ad", "X-B3-Spanid": "a2fb4a1d1a96d312"},
+		{"path": "/items/broken?x=1"},
+	}
+	for _, headers := range requests {
+		req := httptest.NewRequest(http.MethodGet, headers["path"], nil)
+		for k, v := range headers {
+			if k != "path" {
+				req.Header.Set(k, v)
+			}
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Cancelling flushes the spans still queued
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(c.requests) != 1 {
+		t.Fatalf("collector got %d export requests, want 1", len(c.requests))
+	}
+	res := c.requests[0].ResourceSpans[0].Resource.Attributes
+	if len(res) == 0 || res[0].Key != "service.name" || *res[0].Value.StringValue != "echobox-test" {
+		t.Errorf("resource attributes = %+v, want service.name echobox-test", res)
+	}
+
+	spans := c.spans()
+	if len(spans) != 3 {
+		t.Fatalf("collector got %d spans, want 3 (the unsampled parent is skipped)", len(spans))
+	}
+
+	w3c := spans[0]
+	if w3c.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || w3c.ParentSpanID != "00f067aa0ba902b7" || w3c.TraceState != "rojo=1" {
+		t.Errorf("W3C span = %+v, want it to continue the incoming trace", w3c)
+	}
+	if w3c.Name != "GET /items/{id}" || w3c.Kind != SpanKindServer || len(w3c.SpanID) != 16 {
+		t.Errorf("W3C span name, kind, id = %q, %d, %q, want GET /items/{id} server span", w3c.Name, w3c.Kind, w3c.SpanID)
+	}
+	if attr(w3c, "http.response.status_code") != "200" || attr(w3c, "url.path") != "/items/1" {
+		t.Errorf("W3C span attributes = %+v", w3c.Attributes)
+	}
+
+	b3 := spans[1]
+	if b3.TraceID != "0000000000000000463ac35c9f6413ad" || b3.ParentSpanID != "a2fb4a1d1a96d312" {
+		t.Errorf("B3 span trace, parent = %q, %q, want the padded B3 trace", b3.TraceID, b3.ParentSpanID)
+	}
+
+	root := spans[2]
+	if len(root.TraceID) != 32 || root.ParentSpanID != "" {
+		t.Errorf("root span trace, parent = %q, %q, want a new trace without parent", root.TraceID, root.ParentSpanID)
+	}
+	if root.Status.Code != StatusError || attr(root, "url.query") != "x=1" {
+		t.Errorf("root span status, query = %+v, %q, want an error status for 502", root.Status, attr(root, "url.query"))
+	}
+	start, end := root.StartTimeUnixNano, root.EndTimeUnixNano
+	if start == "" || end < start {
+		t.Errorf("root span times = %s..%s, want start before end", start, end)
+	}
+}
+
+func TestExporter_Drops(t *testing.T) {
+	e := NewExporter(ExporterOptions{Endpoint: "http://127.0.0.1:1"})
+	for range defaultQueueSize {
+		if !e.Export(Span{}) {
+			t.Fatal("Export() = false before the queue is full")
+		}
+	}
+	if e.Export(Span{}) {
+		t.Error("Export() = true with a full queue, want the span dropped")
+	}
+}
@@ -0,0 +1,128 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Elagoht/echobox/internal/requestid"
+	"github.com/Elagoht/echobox/internal/response"
+)
+
+// Handler records a server span for every request, continuing the incoming
+// W3C or B3 trace context. Requests whose parent was not sampled are not
+// exported.
+func (e *Exporter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span, sampled := newSpan(Parse(r.Header))
+		if !sampled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rw := &response.Recorder{ResponseWriter: w}
+		defer func() {
+			// Export aborted requests too
+			e.Export(finishSpan(span, r, rw.Status, start, time.Now()))
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// newSpan starts a span as a child of the incoming context, or as the root
+// of a new trace without one.
+func newSpan(info *Info) (Span, bool) {
+	span := Span{SpanID: randomHex(8), Kind: SpanKindServer}
+	switch {
+	case info != nil && info.TraceParent != nil:
+		tp := info.TraceParent
+		span.TraceID, span.ParentSpanID = tp.TraceID, tp.ParentID
+		span.TraceState = formatTraceState(info.TraceState)
+		return span, tp.Sampled
+	case info != nil && info.B3 != nil && info.B3.checkIDs() == nil:
+		b3 := info.B3
+		// 64-bit B3 trace IDs are left-padded to 128 bits
+		span.TraceID = strings.Repeat("0", 32-len(b3.TraceID)) + b3.TraceID
+		span.ParentSpanID = b3.SpanID
+		return span, b3.Sampled == nil || *b3.Sampled
+	case info != nil && info.B3 != nil && info.B3.Sampled != nil && !*info.B3.Sampled:
+		return span, false
+	}
+	span.TraceID = randomHex(16)
+	return span, true
+}
+
+func finishSpan(span Span, r *http.Request, status int, start, end time.Time) Span {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	route := r.Pattern
+	if _, path, ok := strings.Cut(route, " "); ok {
+		// The method is part of the span name already
+		route = path
+	}
+	if route == "" {
+		route = r.URL.Path
+	}
+	span.Name = r.Method + " " + route
+	span.StartTimeUnixNano = unixNano(start)
+	span.EndTimeUnixNano = unixNano(end)
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	span.Attributes = []Attribute{
+		String("http.request.method", r.Method),
+		String("http.route", route),
+		String("url.path", r.URL.Path),
+		String("url.scheme", scheme),
+		String("server.address", r.Host),
+		String("network.protocol.version", protocolVersion(r)),
+		Int("http.response.status_code", int64(status)),
+	}
+	if r.URL.RawQuery != "" {
+		span.Attributes = append(span.Attributes, String("url.query", r.URL.RawQuery))
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		span.Attributes = append(span.Attributes, String("client.address", host))
+	}
+	if ua := r.UserAgent(); ua != "" {
+		span.Attributes = append(span.Attributes, String("user_agent.original", ua))
+	}
+	if id := requestid.FromContext(r.Context()); id != "" {
+		span.Attributes = append(span.Attributes, String("echobox.request_id", id))
+	}
+
+	// Server spans only mark server errors as failed
+	if status >= 500 {
+		span.Status = Status{Code: StatusError, Message: http.StatusText(status)}
+	}
+	return span
+}
+
+func protocolVersion(r *http.Request) string {
+	if r.ProtoMinor == 0 && r.ProtoMajor > 1 {
+		return strconv.Itoa(r.ProtoMajor)
+	}
+	return strconv.Itoa(r.ProtoMajor) + "." + strconv.Itoa(r.ProtoMinor)
+}
+
+func formatTraceState(members []Member) string {
+	entries := make([]string, len(members))
+	for i, m := range members {
+		entries[i] = m.Key + "=" + m.Value
+	}
+	return strings.Join(entries, ",")
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,66 @@
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version is set at build time with
+// -ldflags "-X github.com/Elagoht/echobox/internal/version.Version=v1.2.3".
+var Version = ""
+
+type Info struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	Platform  string `json:"platform"`
+}
+
+// Get reports the linked version, falling back to the module version recorded
+// by go install and finally to "dev".
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+			info.Version = build.Main.Version
+		}
+		for _, s := range build.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info.Commit = s.Value
+			case "vcs.time":
+				info.BuildTime = s.Value
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
+
+func (i Info) String() string {
+	s := "echobox " + i.Version
+	if i.Commit != "" {
+		commit := i.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		s += " (" + commit
+		if i.Modified {
+			s += ", modified"
+		}
+		s += ")"
+	}
+	return s + " " + i.GoVersion + " " + i.Platform
+}
@@ -0,0 +1,44 @@
+package version
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	info := Get()
+
+	if info.Version == "" {
+		t.Error("Get().Version is empty, want at least dev")
+	}
+	if !strings.HasPrefix(info.GoVersion, "go") {
+		t.Errorf("Get().GoVersion = %q, want a Go version", info.GoVersion)
+	}
+	if info.Platform == "" {
+		t.Error("Get().Platform is empty")
+	}
+}
+
+func TestGet_LinkedVersion(t *testing.T) {
+	old := Version
+	defer func() { Version = old }()
+
+	Version = "v9.9.9"
+	if got := Get().Version; got != "v9.9.9" {
+		t.Errorf("Get().Version = %q, want the linked v9.9.9", got)
+	}
+}
+
+func TestInfo_String(t *testing.T) {
+	info := Info{Version: "v1.0.0", GoVersion: "go1.25.0", Platform: "linux/amd64", Commit: "0123456789abcdef", Modified: true}
+
+	want := "echobox v1.0.0 (0123456789ab, modified) go1.25.0 linux/amd64"
+	if got := info.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	info.Commit = ""
+	if got := info.String(); got != "echobox v1.0.0 go1.25.0 linux/amd64" {
+		t.Errorf("String() without commit = %q", got)
+	}
+}
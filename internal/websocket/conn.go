@@ -0,0 +1,367 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xA
+)
+
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+const DefaultMaxMessageSize = 16 << 20
+
+const (
+	finBit  = 0x80
+	rsv1Bit = 0x40
+	rsvMask = 0x70
+	maskBit = 0x80
+)
+
+// deflateTail terminates a compressed message: the sync marker stripped by the
+// sender plus an empty final stored block so the flate reader ends cleanly.
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Reason)
+}
+
+func IsValidCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003:
+		return true
+	case code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+type Conn struct {
+	Subprotocol    string
+	MaxMessageSize int64
+
+	conn    net.Conn
+	br      *bufio.Reader
+	client  bool
+	deflate bool
+	writeMu sync.Mutex
+
+	// State of a fragmented message still being received.
+	partOp         int
+	partPayload    []byte
+	partCompressed bool
+}
+
+func NewConn(c net.Conn, br *bufio.Reader, client, deflate bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(c)
+	}
+	return &Conn{
+		MaxMessageSize: DefaultMaxMessageSize,
+		conn:           c,
+		br:             br,
+		client:         client,
+		deflate:        deflate,
+	}
+}
+
+func (c *Conn) Compressed() bool {
+	return c.deflate
+}
+
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// ReadMessage returns the next complete message. Fragmented data messages are
+// reassembled; control frames are returned as they arrive, even between
+// fragments, so the caller decides how to answer pings and closes. Protocol
+// violations are reported as *CloseError carrying the code to send the peer.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	for {
+		fin, rsv, frameOp, data, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if frameOp >= OpClose {
+			if !fin || len(data) > 125 || rsv != 0 {
+				return 0, nil, &CloseError{Code: CloseProtocolError, Reason: "invalid control frame"}
+			}
+			if frameOp != OpClose && frameOp != OpPing && frameOp != OpPong {
+				return 0, nil, &CloseError{Code: CloseProtocolError, Reason: "unknown opcode"}
+			}
+			return frameOp, data, nil
+		}
+
+		started := c.partOp != 0
+		switch {
+		case frameOp == OpContinuation && !started:
+			return 0, nil, &CloseError{Code: CloseProtocolError, Reason: "unexpected continuation frame"}
+		case frameOp != OpContinuation && started:
+			return 0, nil, &CloseError{Code: CloseProtocolError, Reason: "expected continuation frame"}
+		case frameOp != OpContinuation && frameOp != OpText && frameOp != OpBinary:
+			return 0, nil, &CloseError{Code: CloseProtocolError, Reason: "unknown opcode"}
+		}
+
+		if frameOp != OpContinuation {
+			c.partOp = frameOp
+			c.partCompressed = rsv&rsv1Bit != 0
+			if c.partCompressed && !c.deflate {
+				return 0, nil, &CloseError{Code: CloseProtocolError, Reason: "unexpected compressed frame"}
+			}
+			if rsv&^rsv1Bit != 0 {
+				return 0, nil, &CloseError{Code: CloseProtocolError, Reason: "reserved bits set"}
+			}
+		} else if rsv != 0 {
+			return 0, nil, &CloseError{Code: CloseProtocolError, Reason: "reserved bits set"}
+		}
+
+		if int64(len(c.partPayload)+len(data)) > c.MaxMessageSize {
+			return 0, nil, &CloseError{Code: CloseMessageTooBig, Reason: "message too big"}
+		}
+		c.partPayload = append(c.partPayload, data...)
+
+		if fin {
+			break
+		}
+	}
+
+	op, payload, compressed := c.partOp, c.partPayload, c.partCompressed
+	c.partOp, c.partPayload, c.partCompressed = 0, nil, false
+
+	if compressed {
+		inflated, err := c.inflate(payload)
+		if err != nil {
+			return 0, nil, err
+		}
+		payload = inflated
+	}
+
+	if op == OpText && !utf8.Valid(payload) {
+		return 0, nil, &CloseError{Code: CloseInvalidPayload, Reason: "invalid UTF-8"}
+	}
+
+	return op, payload, nil
+}
+
+func (c *Conn) readFrame() (fin bool, rsv byte, op int, data []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.br, header[:]); err != nil {
+		return
+	}
+
+	fin = header[0]&finBit != 0
+	rsv = header[0] & rsvMask
+	op = int(header[0] & 0x0f)
+	masked := header[1]&maskBit != 0
+	length := uint64(header[1] & 0x7f)
+
+	if masked == c.client {
+		err = &CloseError{Code: CloseProtocolError, Reason: "incorrect frame masking"}
+		return
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if length > uint64(c.MaxMessageSize) {
+		err = &CloseError{Code: CloseMessageTooBig, Reason: "message too big"}
+		return
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, key[:]); err != nil {
+			return
+		}
+	}
+
+	data = make([]byte, length)
+	if _, err = io.ReadFull(c.br, data); err != nil {
+		return
+	}
+	if masked {
+		maskBytes(key, data)
+	}
+	return
+}
+
+func (c *Conn) inflate(payload []byte) ([]byte, error) {
+	fr := flate.NewReader(io.MultiReader(bytes.NewReader(payload), bytes.NewReader(deflateTail)))
+	defer fr.Close()
+
+	out, err := io.ReadAll(io.LimitReader(fr, c.MaxMessageSize+1))
+	if err != nil {
+		return nil, &CloseError{Code: CloseInvalidPayload, Reason: "invalid compressed data"}
+	}
+	if int64(len(out)) > c.MaxMessageSize {
+		return nil, &CloseError{Code: CloseMessageTooBig, Reason: "message too big"}
+	}
+	return out, nil
+}
+
+// WriteMessage sends data as a single frame, compressing text and binary
+// messages when permessage-deflate was negotiated.
+func (c *Conn) WriteMessage(op int, data []byte) error {
+	var rsv byte
+	if c.deflate && (op == OpText || op == OpBinary) {
+		compressed, err := deflate(data)
+		if err != nil {
+			return err
+		}
+		data = compressed
+		rsv = rsv1Bit
+	}
+	return c.writeFrame(true, rsv, op, data)
+}
+
+func (c *Conn) WriteClose(code int, reason string) error {
+	var payload []byte
+	if code != CloseNoStatus {
+		payload = binary.BigEndian.AppendUint16(nil, uint16(code))
+		payload = append(payload, reason...)
+	}
+	return c.writeFrame(true, 0, OpClose, payload)
+}
+
+func (c *Conn) writeFrame(fin bool, rsv byte, op int, data []byte) error {
+	header := make([]byte, 0, 14)
+
+	first := rsv | byte(op)
+	if fin {
+		first |= finBit
+	}
+	header = append(header, first)
+
+	var maskFlag byte
+	if c.client {
+		maskFlag = maskBit
+	}
+
+	switch n := len(data); {
+	case n <= 125:
+		header = append(header, maskFlag|byte(n))
+	case n <= 0xffff:
+		header = append(header, maskFlag|126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, maskFlag|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	if c.client {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		header = append(header, key[:]...)
+		masked := make([]byte, len(data))
+		copy(masked, data)
+		maskBytes(key, masked)
+		data = masked
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if _, err := c.conn.Write(append(header, data...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := fw.Flush(); err != nil {
+		return nil, err
+	}
+
+	out := buf.Bytes()
+	if !bytes.HasSuffix(out, deflateTail[:4]) {
+		return nil, errors.New("websocket: unexpected deflate output")
+	}
+	return out[:len(out)-4], nil
+}
+
+func maskBytes(key [4]byte, data []byte) {
+	for i := range data {
+		data[i] ^= key[i%4]
+	}
+}
+
+// ParseClose splits a close frame payload into its status code and reason.
+func ParseClose(payload []byte) (int, string, error) {
+	switch {
+	case len(payload) == 0:
+		return CloseNoStatus, "", nil
+	case len(payload) == 1:
+		return 0, "", &CloseError{Code: CloseProtocolError, Reason: "invalid close payload"}
+	}
+
+	code := int(binary.BigEndian.Uint16(payload))
+	reason := payload[2:]
+	if !IsValidCloseCode(code) {
+		return 0, "", &CloseError{Code: CloseProtocolError, Reason: "invalid close code"}
+	}
+	if !utf8.Valid(reason) {
+		return 0, "", &CloseError{Code: CloseInvalidPayload, Reason: "invalid UTF-8 in close reason"}
+	}
+	return code, string(reason), nil
+}
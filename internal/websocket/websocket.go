@@ -0,0 +1,181 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var ErrBadHandshake = errors.New("websocket: bad handshake")
+
+type UpgradeOptions struct {
+	// Subprotocols lists the protocols the server agrees to speak, in order
+	// of preference. When empty, the first protocol offered by the client is
+	// accepted.
+	Subprotocols []string
+	// Deflate allows negotiating permessage-deflate (RFC 7692).
+	Deflate bool
+}
+
+func AcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func IsUpgrade(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") &&
+		headerContains(r.Header, "Upgrade", "websocket")
+}
+
+func Upgrade(w http.ResponseWriter, r *http.Request, opts UpgradeOptions) (*Conn, error) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "WebSocket upgrade requires GET", http.StatusMethodNotAllowed)
+		return nil, fmt.Errorf("%w: method %s", ErrBadHandshake, r.Method)
+	}
+	// HEAD is answered like a GET that asked for no upgrade
+	if !IsUpgrade(r) || r.Method == http.MethodHead {
+		w.Header().Set("Upgrade", "websocket")
+		http.Error(w, "WebSocket upgrade required", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("%w: missing upgrade headers", ErrBadHandshake)
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("%w: unsupported version %q", ErrBadHandshake, r.Header.Get("Sec-WebSocket-Version"))
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "Invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, fmt.Errorf("%w: invalid key", ErrBadHandshake)
+	}
+
+	subprotocol := selectSubprotocol(headerTokens(r.Header, "Sec-WebSocket-Protocol"), opts.Subprotocols)
+	deflate := opts.Deflate && offersDeflate(r.Header)
+
+	// ResponseController sees through middleware that wraps the writer
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if errors.Is(err, http.ErrNotSupported) {
+		http.Error(w, "WebSocket not supported on this connection", http.StatusInternalServerError)
+		return nil, fmt.Errorf("%w: response does not support hijacking", ErrBadHandshake)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("websocket: hijack failed: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	b.WriteString("Upgrade: websocket\r\n")
+	b.WriteString("Connection: Upgrade\r\n")
+	b.WriteString("Sec-WebSocket-Accept: " + AcceptKey(key) + "\r\n")
+	if subprotocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if deflate {
+		b.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	b.WriteString("\r\n")
+
+	if _, err := netConn.Write([]byte(b.String())); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("websocket: writing handshake: %w", err)
+	}
+
+	conn := NewConn(netConn, rw.Reader, false, deflate)
+	conn.Subprotocol = subprotocol
+	return conn, nil
+}
+
+func selectSubprotocol(offered, supported []string) string {
+	if len(offered) == 0 {
+		return ""
+	}
+	if len(supported) == 0 {
+		return offered[0]
+	}
+	for _, s := range supported {
+		for _, o := range offered {
+			if s == o {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// offersDeflate reports whether the client offered permessage-deflate with
+// parameters we can honour. Every session runs without context takeover, and
+// compress/flate always uses the full 32 KiB window, so offers restricting
+// server_max_window_bits are declined.
+func offersDeflate(h http.Header) bool {
+	for _, offer := range headerTokens(h, "Sec-WebSocket-Extensions") {
+		params := strings.Split(offer, ";")
+		if strings.TrimSpace(params[0]) != "permessage-deflate" {
+			continue
+		}
+		acceptable := true
+		for _, p := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if name == "server_max_window_bits" && strings.Trim(value, `"`) != "15" {
+				acceptable = false
+			}
+		}
+		if acceptable {
+			return true
+		}
+	}
+	return false
+}
+
+func headerTokens(h http.Header, name string) []string {
+	var tokens []string
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return tokens
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, t := range headerTokens(h, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// Dial performs the client side of the opening handshake over an existing
+// connection. It is used by tests and tools that talk to the /ws endpoint.
+func Dial(netConn net.Conn, req *http.Request, deflate bool) (*Conn, *http.Response, error) {
+	br := bufio.NewReader(netConn)
+	if err := req.Write(netConn); err != nil {
+		return nil, nil, err
+	}
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, resp, fmt.Errorf("%w: status %s", ErrBadHandshake, resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != AcceptKey(req.Header.Get("Sec-WebSocket-Key")) {
+		return nil, resp, fmt.Errorf("%w: mismatched accept key", ErrBadHandshake)
+	}
+
+	negotiated := deflate && strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+	conn := NewConn(netConn, br, true, negotiated)
+	conn.Subprotocol = resp.Header.Get("Sec-WebSocket-Protocol")
+	return conn, resp, nil
+}
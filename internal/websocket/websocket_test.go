@@ -0,0 +1,337 @@
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// Example from RFC 6455 section 1.3
+	got := AcceptKey("dGhlIHNhbXBsZSBub25jZQ==")
+	if got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("AcceptKey() = %v, want s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", got)
+	}
+}
+
+func TestSelectSubprotocol(t *testing.T) {
+	tests := []struct {
+		name      string
+		offered   []string
+		supported []string
+		want      string
+	}{
+		{"nothing offered", nil, []string{"chat"}, ""},
+		{"accept client's first", []string{"chat", "json"}, nil, "chat"},
+		{"server preference wins", []string{"chat", "json"}, []string{"json", "chat"}, "json"},
+		{"no overlap", []string{"chat"}, []string{"json"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectSubprotocol(tt.offered, tt.supported); got != tt.want {
+				t.Errorf("selectSubprotocol() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOffersDeflate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"permessage-deflate", true},
+		{"permessage-deflate; client_max_window_bits", true},
+		{"permessage-deflate; server_max_window_bits=10", false},
+		{"permessage-deflate; server_max_window_bits=10, permessage-deflate", true},
+		{"x-webkit-deflate-frame", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			h := http.Header{}
+			if tt.header != "" {
+				h.Set("Sec-WebSocket-Extensions", tt.header)
+			}
+			if got := offersDeflate(h); got != tt.want {
+				t.Errorf("offersDeflate(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func pipeConns(deflate bool) (server, client *Conn) {
+	s, c := net.Pipe()
+	return NewConn(s, nil, false, deflate), NewConn(c, nil, true, deflate)
+}
+
+func TestConn_RoundTrip(t *testing.T) {
+	for _, deflate := range []bool{false, true} {
+		server, client := pipeConns(deflate)
+
+		messages := []struct {
+			op   int
+			data []byte
+		}{
+			{OpText, []byte("hello")},
+			{OpBinary, []byte{0x00, 0xff, 0x10}},
+			{OpText, []byte(strings.Repeat("a", 70000))},
+			{OpPing, []byte("ping")},
+		}
+
+		go func() {
+			for _, m := range messages {
+				client.WriteMessage(m.op, m.data)
+			}
+		}()
+
+		for _, m := range messages {
+			op, data, err := server.ReadMessage()
+			if err != nil {
+				t.Fatalf("deflate=%v: ReadMessage() error = %v", deflate, err)
+			}
+			if op != m.op {
+				t.Errorf("deflate=%v: ReadMessage() op = %v, want %v", deflate, op, m.op)
+			}
+			if !bytes.Equal(data, m.data) {
+				t.Errorf("deflate=%v: ReadMessage() data length = %d, want %d", deflate, len(data), len(m.data))
+			}
+		}
+
+		server.Close()
+		client.Close()
+	}
+}
+
+func TestConn_Fragmented(t *testing.T) {
+	server, client := pipeConns(false)
+	defer server.Close()
+	defer client.Close()
+
+	go func() {
+		client.writeFrame(false, 0, OpText, []byte("hel"))
+		client.writeFrame(true, 0, OpPing, []byte("mid"))
+		client.writeFrame(true, 0, OpContinuation, []byte("lo"))
+	}()
+
+	op, data, err := server.ReadMessage()
+	if err != nil || op != OpPing || string(data) != "mid" {
+		t.Fatalf("ReadMessage() = %v, %q, %v, want interleaved ping", op, data, err)
+	}
+
+	op, data, err = server.ReadMessage()
+	if err != nil || op != OpText || string(data) != "hello" {
+		t.Fatalf("ReadMessage() = %v, %q, %v, want reassembled text", op, data, err)
+	}
+}
+
+func TestConn_ProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		write    func(c *Conn)
+		wantCode int
+	}{
+		{
+			name:     "unmasked client frame",
+			write:    func(c *Conn) { c.client = false; c.writeFrame(true, 0, OpText, []byte("x")) },
+			wantCode: CloseProtocolError,
+		},
+		{
+			name:     "invalid UTF-8 text",
+			write:    func(c *Conn) { c.writeFrame(true, 0, OpText, []byte{0xff, 0xfe}) },
+			wantCode: CloseInvalidPayload,
+		},
+		{
+			name:     "unexpected continuation",
+			write:    func(c *Conn) { c.writeFrame(true, 0, OpContinuation, []byte("x")) },
+			wantCode: CloseProtocolError,
+		},
+		{
+			name:     "fragmented control frame",
+			write:    func(c *Conn) { c.writeFrame(false, 0, OpPing, nil) },
+			wantCode: CloseProtocolError,
+		},
+		{
+			name:     "compressed without negotiation",
+			write:    func(c *Conn) { c.writeFrame(true, rsv1Bit, OpText, []byte("x")) },
+			wantCode: CloseProtocolError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := pipeConns(false)
+			defer server.Close()
+			defer client.Close()
+
+			go tt.write(client)
+
+			_, _, err := server.ReadMessage()
+			var closeErr *CloseError
+			if !errors.As(err, &closeErr) {
+				t.Fatalf("ReadMessage() error = %v, want *CloseError", err)
+			}
+			if closeErr.Code != tt.wantCode {
+				t.Errorf("ReadMessage() close code = %v, want %v", closeErr.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestConn_MessageTooBig(t *testing.T) {
+	server, client := pipeConns(false)
+	defer server.Close()
+	defer client.Close()
+	server.MaxMessageSize = 10
+
+	go client.WriteMessage(OpBinary, make([]byte, 11))
+
+	_, _, err := server.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseMessageTooBig {
+		t.Errorf("ReadMessage() error = %v, want close 1009", err)
+	}
+}
+
+func TestParseClose(t *testing.T) {
+	tests := []struct {
+		name       string
+		payload    []byte
+		wantCode   int
+		wantReason string
+		wantErr    bool
+	}{
+		{"empty", nil, CloseNoStatus, "", false},
+		{"code and reason", []byte{0x03, 0xe8, 'b', 'y', 'e'}, CloseNormal, "bye", false},
+		{"one byte", []byte{0x03}, 0, "", true},
+		{"reserved code", []byte{0x03, 0xed}, 0, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, reason, err := ParseClose(tt.payload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseClose() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if code != tt.wantCode || reason != tt.wantReason {
+				t.Errorf("ParseClose() = %v, %q, want %v, %q", code, reason, tt.wantCode, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestUpgrade_Rejections(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{
+			name:       "not an upgrade",
+			method:     http.MethodGet,
+			wantStatus: http.StatusUpgradeRequired,
+		},
+		{
+			name:   "wrong method",
+			method: http.MethodPost,
+			headers: map[string]string{
+				"Connection": "Upgrade",
+				"Upgrade":    "websocket",
+			},
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "unsupported version",
+			method: http.MethodGet,
+			headers: map[string]string{
+				"Connection":            "Upgrade",
+				"Upgrade":               "websocket",
+				"Sec-WebSocket-Version": "8",
+			},
+			wantStatus: http.StatusUpgradeRequired,
+		},
+		{
+			name:   "invalid key",
+			method: http.MethodGet,
+			headers: map[string]string{
+				"Connection":            "keep-alive, Upgrade",
+				"Upgrade":               "websocket",
+				"Sec-WebSocket-Version": "13",
+				"Sec-WebSocket-Key":     "short",
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/ws", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+
+			if _, err := Upgrade(w, req, UpgradeOptions{}); !errors.Is(err, ErrBadHandshake) {
+				t.Errorf("Upgrade() error = %v, want ErrBadHandshake", err)
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("Upgrade() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestDial_Handshake(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r, UpgradeOptions{Subprotocols: []string{"json"}, Deflate: true})
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		op, data, err := conn.ReadMessage()
+		if err == nil {
+			conn.WriteMessage(op, data)
+		}
+	}))
+	defer server.Close()
+
+	netConn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	defer netConn.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Protocol", "chat, json")
+	req.Header.Set("Sec-WebSocket-Extensions", "permessage-deflate")
+
+	conn, _, err := Dial(netConn, req, true)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	if conn.Subprotocol != "json" {
+		t.Errorf("Subprotocol = %q, want json", conn.Subprotocol)
+	}
+	if !conn.Compressed() {
+		t.Error("Compressed() = false, want true")
+	}
+
+	if err := conn.WriteMessage(OpText, []byte("hi")); err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+	op, data, err := conn.ReadMessage()
+	if err != nil || op != OpText || string(data) != "hi" {
+		t.Errorf("ReadMessage() = %v, %q, %v, want text hi", op, data, err)
+	}
+}
@@ -0,0 +1,160 @@
+// Package wire captures the request line and headers of HTTP/1 requests as
+// they arrive, before net/http canonicalizes their names and loses their
+// order.
+package wire
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// DefaultLimit bounds the bytes kept per request head.
+const DefaultLimit = 1 << 20
+
+// Listener wraps every accepted connection in a Conn.
+type Listener struct {
+	net.Listener
+	Limit int
+}
+
+func NewListener(ln net.Listener, limit int) *Listener {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	return &Listener{Listener: ln, Limit: limit}
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{Conn: conn, limit: l.Limit}, nil
+}
+
+// Conn records what it reads until the blank line ending the headers, then
+// ignores the body until Reset readies it for the next request.
+type Conn struct {
+	net.Conn
+	limit int
+
+	mu   sync.Mutex
+	head []byte
+	done bool
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.record(p[:n])
+	}
+	return n, err
+}
+
+func (c *Conn) record(p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done {
+		return
+	}
+	if len(c.head) == 0 {
+		// Empty lines before a request line are ignored by the server too
+		p = bytes.TrimLeft(p, "\r\n")
+	}
+
+	// The terminator may straddle two reads
+	from := max(0, len(c.head)-3)
+	c.head = append(c.head, p...)
+	if i := headerEnd(c.head[from:]); i >= 0 {
+		c.head = c.head[:from+i]
+		c.done = true
+	} else if len(c.head) >= c.limit {
+		c.head = c.head[:c.limit]
+		c.done = true
+	}
+}
+
+// headerEnd returns the length of the head in b up to and including the
+// blank line, which net/http accepts with bare LFs as well as CRLFs.
+func headerEnd(b []byte) int {
+	end := -1
+	if i := bytes.Index(b, []byte("\r\n\r\n")); i >= 0 {
+		end = i + 4
+	}
+	if i := bytes.Index(b, []byte("\n\n")); i >= 0 && (end < 0 || i+2 < end) {
+		end = i + 2
+	}
+	return end
+}
+
+// Head returns the captured request line and headers, or nil while they are
+// still arriving.
+func (c *Conn) Head() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.done {
+		return nil
+	}
+	return bytes.Clone(c.head)
+}
+
+// Reset starts capturing the next request on the connection.
+func (c *Conn) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.head = c.head[:0]
+	c.done = false
+}
+
+// NetConn returns the wrapped connection.
+func (c *Conn) NetConn() net.Conn {
+	return c.Conn
+}
+
+type contextKey struct{}
+
+// ConnContext is an http.Server ConnContext hook recording the connection of
+// each request for FromContext.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// ConnState is an http.Server ConnState hook resetting the capture once a
+// response is done. Requests pipelined behind it were already read, so they
+// are not captured.
+func ConnState(c net.Conn, state http.ConnState) {
+	if state != http.StateIdle {
+		return
+	}
+	if wc := find(c); wc != nil {
+		wc.Reset()
+	}
+}
+
+// FromContext returns the raw head of the request served with ctx, or nil
+// when its connection is not captured.
+func FromContext(ctx context.Context) []byte {
+	c, _ := ctx.Value(contextKey{}).(net.Conn)
+	if wc := find(c); wc != nil {
+		return wc.Head()
+	}
+	return nil
+}
+
+// find looks for a Conn through wrappers such as *proxyproto.Conn.
+func find(c net.Conn) *Conn {
+	for c != nil {
+		if wc, ok := c.(*Conn); ok {
+			return wc
+		}
+		wrapper, ok := c.(interface{ NetConn() net.Conn })
+		if !ok {
+			return nil
+		}
+		c = wrapper.NetConn()
+	}
+	return nil
+}
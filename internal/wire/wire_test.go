@@ -0,0 +1,86 @@
+package wire
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+)
+
+// pipeConn reads the chunks it was given, one per Read.
+type pipeConn struct {
+	net.Conn
+	chunks []string
+}
+
+func (c *pipeConn) Read(p []byte) (int, error) {
+	if len(c.chunks) == 0 {
+		return 0, net.ErrClosed
+	}
+	n := copy(p, c.chunks[0])
+	c.chunks = c.chunks[1:]
+	return n, nil
+}
+
+func capture(limit int, chunks ...string) *Conn {
+	c := &Conn{Conn: &pipeConn{chunks: chunks}, limit: limit}
+	buf := make([]byte, 64)
+	for range chunks {
+		c.Read(buf)
+	}
+	return c
+}
+
+func TestConn_Head(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  int
+		chunks []string
+		want   string
+	}{
+		{"one read with body", DefaultLimit, []string{"GET / HTTP/1.1\r\nA: 1\r\n\r\nbody"}, "GET / HTTP/1.1\r\nA: 1\r\n\r\n"},
+		{"split terminator", DefaultLimit, []string{"GET / HTTP/1.1\r\nA: 1\r", "\n\r", "\nbody"}, "GET / HTTP/1.1\r\nA: 1\r\n\r\n"},
+		{"bare LF", DefaultLimit, []string{"GET / HTTP/1.1\nA: 1\n\nbody"}, "GET / HTTP/1.1\nA: 1\n\n"},
+		{"leading empty lines", DefaultLimit, []string{"\r\n", "GET / HTTP/1.1\r\n\r\n"}, "GET / HTTP/1.1\r\n\r\n"},
+		{"limit", 8, []string{"GET / HTTP/1.1\r\n"}, "GET / HT"},
+		{"incomplete", DefaultLimit, []string{"GET / HTTP/1.1\r\n"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(capture(tt.limit, tt.chunks...).Head()); got != tt.want {
+				t.Errorf("Head() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnState_Reset(t *testing.T) {
+	c := capture(DefaultLimit, "GET /a HTTP/1.1\r\n\r\n", "later body")
+	ctx := ConnContext(context.Background(), c)
+	if got := string(FromContext(ctx)); got != "GET /a HTTP/1.1\r\n\r\n" {
+		t.Fatalf("FromContext() = %q, want the first head", got)
+	}
+
+	ConnState(c, http.StateActive)
+	if FromContext(ctx) == nil {
+		t.Error("FromContext() = nil after StateActive, want the head kept")
+	}
+
+	ConnState(c, http.StateIdle)
+	c.Conn.(*pipeConn).chunks = []string{"GET /b HTTP/1.1\r\n\r\n"}
+	c.Read(make([]byte, 64))
+	if got := string(FromContext(ctx)); got != "GET /b HTTP/1.1\r\n\r\n" {
+		t.Errorf("FromContext() = %q, want the next head after StateIdle", got)
+	}
+}
+
+func TestFromContext_Uncaptured(t *testing.T) {
+	if got := FromContext(context.Background()); got != nil {
+		t.Errorf("FromContext() = %q, want nil without a connection", got)
+	}
+	ctx := ConnContext(context.Background(), &pipeConn{})
+	if got := FromContext(ctx); got != nil {
+		t.Errorf("FromContext() = %q, want nil for an uncaptured connection", got)
+	}
+}